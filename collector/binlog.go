@@ -65,8 +65,8 @@ func (ScrapeBinlogSize) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeBinlogSize) Version() float64 {
-	return 5.1
+func (ScrapeBinlogSize) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -0,0 +1,183 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Tunable flags.
+var scraperMinIntervals = scraperDurations{}
+
+func init() {
+	kingpin.Flag(
+		"collect.min_interval",
+		"Minimum time between refreshes of a collector as <collector>=<duration>, e.g. info_schema.tables=5m. Cached metrics are served in between. Can be repeated.",
+	).PlaceHolder("COLLECTOR=DURATION").SetValue(scraperMinIntervals)
+}
+
+// Metric descriptors.
+var (
+	scraperCacheAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "collector_cache_age_seconds"),
+		"Age of the metrics served for a collector with a minimum refresh interval.",
+		[]string{"collector"}, nil,
+	)
+)
+
+// CachedScraper is implemented by scrapers which are too expensive to run on every
+// scrape. Their metrics are refreshed at most once per MinInterval.
+type CachedScraper interface {
+	Scraper
+
+	// MinInterval between two runs of the scraper, 0 to run on every scrape.
+	MinInterval() time.Duration
+}
+
+// scraperDurations holds the values of a repeatable <collector>=<duration> flag
+// by scraper name.
+type scraperDurations map[string]time.Duration
+
+func (m scraperDurations) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected <collector>=<duration>, got %q", value)
+	}
+	name := strings.TrimPrefix(parts[0], "collect.")
+	interval, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid duration for %s: %s", name, err)
+	}
+	m[name] = interval
+	return nil
+}
+
+func (m scraperDurations) String() string {
+	return strings.Join(m.Values(), ",")
+}
+
+// Values returns the values as passed on the command line, ordered by scraper name.
+func (m scraperDurations) Values() []string {
+	var s []string
+	for name, interval := range m {
+		s = append(s, name+"="+interval.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Reset removes all values, e.g. before setting them from the config file.
+func (m scraperDurations) Reset() {
+	for name := range m {
+		delete(m, name)
+	}
+}
+
+// IsCumulative allows the flag to be repeated.
+func (m scraperDurations) IsCumulative() bool {
+	return true
+}
+
+// scraperMinInterval returns the minimum refresh interval of the scraper, the
+// collect.min_interval flag takes precedence over what the scraper declares.
+func scraperMinInterval(scraper Scraper) time.Duration {
+	if interval, ok := scraperMinIntervals[scraper.Name()]; ok {
+		return interval
+	}
+	if cached, ok := scraper.(CachedScraper); ok {
+		return cached.MinInterval()
+	}
+	return 0
+}
+
+// scrapeCache keeps the last metrics of the cached scrapers of one target.
+type scrapeCache struct {
+	mu      sync.Mutex
+	entries map[string]*scrapeCacheEntry
+}
+
+type scrapeCacheEntry struct {
+	// lock is held while refreshing. It is a channel so waiting can be cancelled.
+	lock      chan struct{}
+	refreshed time.Time
+	metrics   []prometheus.Metric
+}
+
+func newScrapeCache() *scrapeCache {
+	return &scrapeCache{entries: map[string]*scrapeCacheEntry{}}
+}
+
+func (c *scrapeCache) entry(name string) *scrapeCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok {
+		e = &scrapeCacheEntry{lock: make(chan struct{}, 1)}
+		c.entries[name] = e
+	}
+	return e
+}
+
+// scrape sends the cached metrics of the scraper if they are younger than interval
+// and runs the scraper otherwise. Only the metrics of successful runs are cached,
+// so a refresh aborted by ctx is retried on the next scrape.
+func (c *scrapeCache) scrape(ctx context.Context, db *sql.DB, scraper Scraper, interval time.Duration, ch chan<- prometheus.Metric) error {
+	e := c.entry(scraper.Name())
+	// Concurrent scrapes wait for a running refresh instead of starting another one.
+	select {
+	case e.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-e.lock }()
+
+	label := "collect." + scraper.Name()
+	if !e.refreshed.IsZero() && time.Since(e.refreshed) < interval {
+		for _, m := range e.metrics {
+			ch <- m
+		}
+		ch <- prometheus.MustNewConstMetric(scraperCacheAgeDesc, prometheus.GaugeValue, time.Since(e.refreshed).Seconds(), label)
+		return nil
+	}
+
+	var (
+		buf       = make(chan prometheus.Metric)
+		metrics   []prometheus.Metric
+		scrapeErr error
+	)
+	go func() {
+		scrapeErr = scraper.Scrape(ctx, db, buf)
+		close(buf)
+	}()
+	for m := range buf {
+		metrics = append(metrics, m)
+		ch <- m
+	}
+	if scrapeErr != nil {
+		return scrapeErr
+	}
+	e.metrics = metrics
+	e.refreshed = time.Now()
+	ch <- prometheus.MustNewConstMetric(scraperCacheAgeDesc, prometheus.GaugeValue, 0, label)
+	return nil
+}
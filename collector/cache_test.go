@@ -0,0 +1,138 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var countingDesc = prometheus.NewDesc("test_runs", "Number of runs of the scraper.", nil, nil)
+
+// countingScraper reports how often it ran and fails when err is set.
+type countingScraper struct {
+	runs int
+	err  error
+}
+
+func (*countingScraper) Name() string               { return "test.counting" }
+func (*countingScraper) Help() string               { return "" }
+func (*countingScraper) Version() MySQLVersion      { return MySQLVersion{5, 1} }
+func (*countingScraper) MinInterval() time.Duration { return time.Hour }
+
+func (s *countingScraper) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.runs++
+	ch <- prometheus.MustNewConstMetric(countingDesc, prometheus.GaugeValue, float64(s.runs))
+	return s.err
+}
+
+func collectCached(cache *scrapeCache, ctx context.Context, scraper Scraper, interval time.Duration) ([]MetricResult, error) {
+	ch := make(chan prometheus.Metric)
+	var err error
+	go func() {
+		err = cache.scrape(ctx, nil, scraper, interval, ch)
+		close(ch)
+	}()
+	var got []MetricResult
+	for m := range ch {
+		got = append(got, readMetric(m))
+	}
+	return got, err
+}
+
+func TestScrapeCache(t *testing.T) {
+	convey.Convey("Metrics are served from the cache within the interval", t, func() {
+		cache := newScrapeCache()
+		scraper := &countingScraper{}
+
+		got, err := collectCached(cache, context.Background(), scraper, time.Hour)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldHaveLength, 2)
+		convey.So(got[0].value, convey.ShouldEqual, 1)
+		convey.So(got[1].labels, convey.ShouldResemble, labelMap{"collector": "collect.test.counting"})
+		convey.So(got[1].value, convey.ShouldEqual, 0)
+
+		got, err = collectCached(cache, context.Background(), scraper, time.Hour)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraper.runs, convey.ShouldEqual, 1)
+		convey.So(got[0].value, convey.ShouldEqual, 1)
+		convey.So(got[1].value, convey.ShouldBeGreaterThan, 0)
+
+		// Refreshed once the interval passed.
+		got, err = collectCached(cache, context.Background(), scraper, time.Nanosecond)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraper.runs, convey.ShouldEqual, 2)
+		convey.So(got[0].value, convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Failed runs are not cached", t, func() {
+		cache := newScrapeCache()
+		scraper := &countingScraper{err: fmt.Errorf("query failed")}
+
+		_, err := collectCached(cache, context.Background(), scraper, time.Hour)
+		convey.So(err, convey.ShouldNotBeNil)
+		scraper.err = nil
+		_, err = collectCached(cache, context.Background(), scraper, time.Hour)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraper.runs, convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("Waiting for a running refresh is aborted with the context", t, func() {
+		cache := newScrapeCache()
+		scraper := &countingScraper{}
+		e := cache.entry(scraper.Name())
+		e.lock <- struct{}{}
+		defer func() { <-e.lock }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := collectCached(cache, ctx, scraper, time.Hour)
+		convey.So(err, convey.ShouldResemble, context.DeadlineExceeded)
+		convey.So(scraper.runs, convey.ShouldEqual, 0)
+	})
+}
+
+func TestScraperMinInterval(t *testing.T) {
+	// Repeated flags accumulate over kingpin.CommandLine.Parse calls.
+	defer func() {
+		for name := range scraperMinIntervals {
+			delete(scraperMinIntervals, name)
+		}
+	}()
+
+	convey.Convey("Intervals", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraperMinInterval(ScrapeGlobalStatus{}), convey.ShouldEqual, 0)
+		convey.So(scraperMinInterval(&countingScraper{}), convey.ShouldEqual, time.Hour)
+
+		_, err = kingpin.CommandLine.Parse([]string{
+			"--collect.min_interval", "collect.global_status=30s",
+			"--collect.min_interval", "test.counting=0s",
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraperMinInterval(ScrapeGlobalStatus{}), convey.ShouldEqual, 30*time.Second)
+		convey.So(scraperMinInterval(&countingScraper{}), convey.ShouldEqual, 0)
+
+		_, err = kingpin.CommandLine.Parse([]string{"--collect.min_interval", "global_status"})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -44,6 +44,10 @@ func readMetric(m prometheus.Metric) MetricResult {
 	if pb.Untyped != nil {
 		return MetricResult{labels: labels, value: pb.GetUntyped().GetValue(), metricType: dto.MetricType_UNTYPED}
 	}
+	// Histograms are compared by their sample count only.
+	if pb.Histogram != nil {
+		return MetricResult{labels: labels, value: float64(pb.GetHistogram().GetSampleCount()), metricType: dto.MetricType_HISTOGRAM}
+	}
 	panic("Unsupported metric type")
 }
 
@@ -52,5 +56,7 @@ func sanitizeQuery(q string) string {
 	q = strings.Replace(q, "(", "\\(", -1)
 	q = strings.Replace(q, ")", "\\)", -1)
 	q = strings.Replace(q, "*", "\\*", -1)
+	q = strings.Replace(q, "+", "\\+", -1)
+	q = strings.Replace(q, "?", "\\?", -1)
 	return q
 }
@@ -0,0 +1,172 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Tunable flags.
+var (
+	exporterConstLabels = constLabels{}
+	exporterRoleLabel   = kingpin.Flag(
+		"exporter.role_label",
+		"Name of a label added to all metrics with the comma separated roles of the instance, see mysql_instance_role. Empty to disable.",
+	).Default("").String()
+)
+
+func init() {
+	kingpin.Flag(
+		"exporter.const_label",
+		"Label added to all metrics as <name>=<value>, e.g. cluster=prod. Can be repeated.",
+	).PlaceHolder("NAME=VALUE").SetValue(exporterConstLabels)
+}
+
+// constLabels holds the exporter.const_label flag values.
+type constLabels prometheus.Labels
+
+func (l constLabels) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected <name>=<value>, got %q", value)
+	}
+	if name := model.LabelName(parts[0]); !name.IsValid() || strings.HasPrefix(parts[0], model.ReservedLabelPrefix) {
+		return fmt.Errorf("invalid label name %q", parts[0])
+	}
+	l[parts[0]] = parts[1]
+	return nil
+}
+
+func (l constLabels) String() string {
+	return strings.Join(l.Values(), ",")
+}
+
+// Values returns the values as passed on the command line, ordered by label name.
+func (l constLabels) Values() []string {
+	var s []string
+	for name, value := range l {
+		s = append(s, name+"="+value)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Reset removes all labels, e.g. before setting them from the config file.
+func (l constLabels) Reset() {
+	for name := range l {
+		delete(l, name)
+	}
+}
+
+// IsCumulative allows the flag to be repeated.
+func (l constLabels) IsCumulative() bool {
+	return true
+}
+
+// labelPairs returns the labels in the order of their names.
+func labelPairs(labels prometheus.Labels) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		name, value := name, value
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	return pairs
+}
+
+// labelledMetric adds labels to a metric, like the const labels of its Desc.
+// Labels the metric already has are kept.
+type labelledMetric struct {
+	prometheus.Metric
+	labels []*dto.LabelPair
+}
+
+func (m labelledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	own := make(map[string]bool, len(out.Label))
+	for _, lp := range out.Label {
+		own[lp.GetName()] = true
+	}
+	for _, lp := range m.labels {
+		if !own[lp.GetName()] {
+			out.Label = append(out.Label, lp)
+		}
+	}
+	sort.Slice(out.Label, func(i, j int) bool { return out.Label[i].GetName() < out.Label[j].GetName() })
+	return nil
+}
+
+// forwardLabelled sends the metrics of in to out with the const labels and the
+// role label of the Exporter. Metrics are held back until the roles of the
+// instance are received, which happens early in the scrape.
+func (e *Exporter) forwardLabelled(in <-chan prometheus.Metric, out chan<- prometheus.Metric, roles <-chan []string) {
+	var (
+		pending []prometheus.Metric
+		labels  []*dto.LabelPair
+		ready   bool
+	)
+	setRoles := func(r []string) {
+		labels, ready = e.labelPairs(r), true
+		for _, m := range pending {
+			out <- labelledMetric{Metric: m, labels: labels}
+		}
+		pending = nil
+	}
+	for {
+		select {
+		case m, ok := <-in:
+			if !ok {
+				if !ready {
+					// mysqld was not reachable, or the roles were sent just before in was closed.
+					select {
+					case r := <-roles:
+						setRoles(r)
+					default:
+						setRoles(nil)
+					}
+				}
+				return
+			}
+			if !ready {
+				pending = append(pending, m)
+				continue
+			}
+			out <- labelledMetric{Metric: m, labels: labels}
+		case r := <-roles:
+			setRoles(r)
+			roles = nil
+		}
+	}
+}
+
+// labelPairs returns the const labels, and the role label if the roles are known.
+func (e *Exporter) labelPairs(roles []string) []*dto.LabelPair {
+	labels := prometheus.Labels{}
+	for name, value := range e.constLabels {
+		labels[name] = value
+	}
+	if e.roleLabel != "" && len(roles) > 0 {
+		labels[e.roleLabel] = strings.Join(roles, ",")
+	}
+	return labelPairs(labels)
+}
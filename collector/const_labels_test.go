@@ -0,0 +1,97 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var labelledDesc = prometheus.NewDesc("test_labelled", "Metric with a role label of its own.", []string{"role"}, nil)
+
+// forward runs forwardLabelled over metrics, sending roles after the first metric if not nil.
+func forward(e *Exporter, metrics []prometheus.Metric, roles []string) []MetricResult {
+	in := make(chan prometheus.Metric)
+	out := make(chan prometheus.Metric)
+	rolesCh := make(chan []string, 1)
+	go func() {
+		e.forwardLabelled(in, out, rolesCh)
+		close(out)
+	}()
+	go func() {
+		for i, m := range metrics {
+			in <- m
+			if i == 0 && roles != nil {
+				rolesCh <- roles
+			}
+		}
+		close(in)
+	}()
+	var got []MetricResult
+	for m := range out {
+		got = append(got, readMetric(m))
+	}
+	return got
+}
+
+func TestConstLabels(t *testing.T) {
+	// Repeated flags accumulate over kingpin.CommandLine.Parse calls.
+	defer func() {
+		for name := range exporterConstLabels {
+			delete(exporterConstLabels, name)
+		}
+	}()
+
+	convey.Convey("Flags", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{
+			"--exporter.const_label", "cluster=prod",
+			"--exporter.const_label", "site=eu=1",
+			"--exporter.role_label", "role",
+		})
+		convey.So(err, convey.ShouldBeNil)
+		e := New(context.Background(), NewPool(dsn), NewMetrics(), nil)
+		convey.So(e.constLabels, convey.ShouldResemble, prometheus.Labels{"cluster": "prod", "site": "eu=1"})
+		convey.So(e.roleLabel, convey.ShouldEqual, "role")
+
+		for _, invalid := range []string{"cluster", "1cluster=prod", "__name__=up"} {
+			_, err = kingpin.CommandLine.Parse([]string{"--exporter.const_label", invalid})
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+
+	convey.Convey("Labels are added to all metrics once the roles are known", t, func() {
+		e := New(context.Background(), NewPool(dsn), NewMetrics(), nil)
+		e.SetConstLabels(prometheus.Labels{"cluster": "prod"}, "role")
+		metrics := []prometheus.Metric{
+			prometheus.MustNewConstMetric(countingDesc, prometheus.GaugeValue, 1),
+			prometheus.MustNewConstMetric(labelledDesc, prometheus.GaugeValue, 2, "own"),
+			prometheus.MustNewConstMetric(countingDesc, prometheus.GaugeValue, 3),
+		}
+
+		got := forward(e, metrics, []string{RoleSource, RoleReplica})
+		convey.So(got, convey.ShouldHaveLength, 3)
+		convey.So(got[0].labels, convey.ShouldResemble, labelMap{"cluster": "prod", "role": "source,replica"})
+		convey.So(got[1].labels, convey.ShouldResemble, labelMap{"cluster": "prod", "role": "own"})
+		convey.So(got[2].labels, convey.ShouldResemble, labelMap{"cluster": "prod", "role": "source,replica"})
+
+		// mysqld is down, the roles are unknown.
+		got = forward(e, metrics, nil)
+		convey.So(got, convey.ShouldHaveLength, 3)
+		convey.So(got[0].labels, convey.ShouldResemble, labelMap{"cluster": "prod"})
+	})
+}
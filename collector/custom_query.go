@@ -0,0 +1,410 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape user defined queries.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// Subsystem.
+const customQuery = "custom_query"
+
+// Tunable flags.
+var (
+	customQueryFile = kingpin.Flag(
+		"collect.custom_query.file",
+		"Path to a YAML file with the queries run by collect.custom_query.",
+	).Default("").String()
+)
+
+// CustomQueryConfig is the content of the collect.custom_query.file.
+type CustomQueryConfig struct {
+	Queries []CustomQuery `yaml:"queries"`
+}
+
+// CustomQuery is a query and the metrics built from its result rows.
+type CustomQuery struct {
+	// Name of the query, the collector is reported as custom_query.<name>.
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+	// MinVersion of MySQL from which the query is run, as "major.minor".
+	MinVersion string `yaml:"min_version"`
+	// Timeout of the query, 0 for the scrape timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// MinInterval between two runs of the query, its metrics are cached in between.
+	MinInterval time.Duration  `yaml:"min_interval"`
+	Metrics     []CustomMetric `yaml:"metrics"`
+}
+
+// CustomMetric maps result columns to a metric.
+type CustomMetric struct {
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	// Type is gauge, counter or histogram.
+	Type string `yaml:"type"`
+	// Labels are the columns whose values become labels of the same name.
+	Labels []string `yaml:"labels"`
+	// Value is the column holding the value, or for histograms the number of observations in the bucket.
+	Value string `yaml:"value"`
+	// Bucket is the column holding the upper bound of the bucket of histograms.
+	// Rows hold the observations of one bucket only, they are made cumulative.
+	Bucket string `yaml:"bucket"`
+	// Sum is the optional column holding the sum of the observations of histograms.
+	Sum string `yaml:"sum"`
+}
+
+// customQueries holds the queries loaded from the collect.custom_query.file.
+var customQueries struct {
+	sync.RWMutex
+	scrapers []Scraper
+}
+
+// LoadCustomQueries (re)loads the collect.custom_query.file. The previous queries
+// are kept if the file is invalid.
+func LoadCustomQueries() error {
+	var scrapers []Scraper
+	if *customQueryFile != "" {
+		content, err := ioutil.ReadFile(*customQueryFile)
+		if err != nil {
+			return err
+		}
+		cfg := &CustomQueryConfig{}
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return fmt.Errorf("failed parsing %s: %s", *customQueryFile, err)
+		}
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("invalid %s: %s", *customQueryFile, err)
+		}
+		for _, q := range cfg.Queries {
+			scrapers = append(scrapers, newCustomQueryScraper(q))
+		}
+	}
+
+	customQueries.Lock()
+	defer customQueries.Unlock()
+	customQueries.scrapers = scrapers
+	return nil
+}
+
+func (c *CustomQueryConfig) validate() error {
+	queries := map[string]bool{}
+	metrics := map[string]bool{}
+	for i, q := range c.Queries {
+		if q.Name == "" {
+			return fmt.Errorf("query %d: name is required", i)
+		}
+		if queries[q.Name] {
+			return fmt.Errorf("query %s: duplicate name", q.Name)
+		}
+		queries[q.Name] = true
+		if q.Query == "" {
+			return fmt.Errorf("query %s: query is required", q.Name)
+		}
+		if q.MinVersion != "" {
+			if _, err := ParseMySQLVersion(q.MinVersion); err != nil {
+				return fmt.Errorf("query %s: %s", q.Name, err)
+			}
+		}
+		if len(q.Metrics) == 0 {
+			return fmt.Errorf("query %s: no metrics", q.Name)
+		}
+		for _, m := range q.Metrics {
+			if err := m.validate(); err != nil {
+				return fmt.Errorf("query %s: metric %s: %s", q.Name, m.Name, err)
+			}
+			if metrics[m.Name] {
+				return fmt.Errorf("query %s: metric %s: duplicate name", q.Name, m.Name)
+			}
+			metrics[m.Name] = true
+		}
+	}
+	return nil
+}
+
+func (m *CustomMetric) validate() error {
+	if !model.IsValidMetricName(model.LabelValue(m.Name)) {
+		return fmt.Errorf("invalid metric name")
+	}
+	for _, label := range m.Labels {
+		if !model.LabelName(label).IsValid() || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("invalid label name %q", label)
+		}
+	}
+	if m.Value == "" {
+		return fmt.Errorf("value is required")
+	}
+	switch m.Type {
+	case "gauge", "counter":
+		if m.Bucket != "" || m.Sum != "" {
+			return fmt.Errorf("bucket and sum are only allowed for histograms")
+		}
+	case "histogram":
+		if m.Bucket == "" {
+			return fmt.Errorf("bucket is required for histograms")
+		}
+	default:
+		return fmt.Errorf("unknown type %q, expected gauge, counter or histogram", m.Type)
+	}
+	return nil
+}
+
+// ScrapeCustomQueries runs the queries of the collect.custom_query.file.
+// Each query is run by the Exporter as its own scraper.
+type ScrapeCustomQueries struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeCustomQueries) Name() string {
+	return customQuery
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeCustomQueries) Help() string {
+	return "Collect the metrics of the queries in collect.custom_query.file"
+}
+
+// Version of MySQL from which scraper is available.
+func (ScrapeCustomQueries) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
+}
+
+// Scrapers returns one scraper per query.
+func (ScrapeCustomQueries) Scrapers() []Scraper {
+	customQueries.RLock()
+	defer customQueries.RUnlock()
+	return customQueries.scrapers
+}
+
+// Scrape runs all queries one after the other, the Exporter runs them individually instead.
+func (s ScrapeCustomQueries) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	for _, scraper := range s.Scrapers() {
+		if err := scraper.Scrape(ctx, db, ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customQueryScraper runs a single custom query.
+type customQueryScraper struct {
+	query   *CustomQuery
+	version MySQLVersion
+	descs   []*prometheus.Desc
+}
+
+func newCustomQueryScraper(q CustomQuery) *customQueryScraper {
+	s := &customQueryScraper{query: &q}
+	if q.MinVersion != "" {
+		// Validated when loading.
+		s.version, _ = ParseMySQLVersion(q.MinVersion)
+	}
+	for _, m := range q.Metrics {
+		s.descs = append(s.descs, prometheus.NewDesc(m.Name, m.Help, m.Labels, nil))
+	}
+	return s
+}
+
+// Name of the Scraper. Should be unique.
+func (s *customQueryScraper) Name() string {
+	return customQuery + "." + s.query.Name
+}
+
+// Help describes the role of the Scraper.
+func (s *customQueryScraper) Help() string {
+	return "Collect the metrics of custom query " + s.query.Name
+}
+
+// Version of MySQL from which scraper is available.
+func (s *customQueryScraper) Version() MySQLVersion {
+	return s.version
+}
+
+// MinInterval between two runs of the query.
+func (s *customQueryScraper) MinInterval() time.Duration {
+	return s.query.MinInterval
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric.
+// Nothing is sent if the query fails, e.g. because two rows yield the same series.
+func (s *customQueryScraper) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	if s.query.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.query.Timeout)
+		defer cancel()
+	}
+
+	rows, err := db.QueryContext(ctx, s.query.Query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columnNames, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(columnNames))
+	for i, name := range columnNames {
+		columns[name] = i
+	}
+	for _, m := range s.query.Metrics {
+		for _, column := range append([]string{m.Value, m.Bucket, m.Sum}, m.Labels...) {
+			if _, ok := columns[column]; column != "" && !ok {
+				return fmt.Errorf("metric %s: column %q not in result", m.Name, column)
+			}
+		}
+	}
+
+	var metrics []prometheus.Metric
+	seen := make([]map[string]bool, len(s.query.Metrics))
+	histograms := make([]*customHistograms, len(s.query.Metrics))
+	for i, m := range s.query.Metrics {
+		if m.Type == "histogram" {
+			histograms[i] = newCustomHistograms()
+		} else {
+			seen[i] = map[string]bool{}
+		}
+	}
+
+	values := make([]sql.NullString, len(columnNames))
+	scanArgs := make([]interface{}, len(columnNames))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	float := func(column string) (float64, error) {
+		v := values[columns[column]]
+		if !v.Valid {
+			return 0, fmt.Errorf("column %q is NULL", column)
+		}
+		return strconv.ParseFloat(v.String, 64)
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return err
+		}
+		for i, m := range s.query.Metrics {
+			labelValues := make([]string, len(m.Labels))
+			for j, label := range m.Labels {
+				labelValues[j] = values[columns[label]].String
+			}
+			value, err := float(m.Value)
+			if err != nil {
+				return fmt.Errorf("metric %s: %s", m.Name, err)
+			}
+			if value < 0 && m.Type != "gauge" {
+				return fmt.Errorf("metric %s: negative value %v in column %q", m.Name, value, m.Value)
+			}
+			if seen[i] != nil {
+				key := strings.Join(labelValues, "\xff")
+				if seen[i][key] {
+					return fmt.Errorf("metric %s: several rows for labels %v", m.Name, labelValues)
+				}
+				seen[i][key] = true
+			}
+			switch m.Type {
+			case "gauge":
+				metrics = append(metrics, prometheus.MustNewConstMetric(s.descs[i], prometheus.GaugeValue, value, labelValues...))
+			case "counter":
+				metrics = append(metrics, prometheus.MustNewConstMetric(s.descs[i], prometheus.CounterValue, value, labelValues...))
+			case "histogram":
+				bound, err := float(m.Bucket)
+				if err != nil {
+					return fmt.Errorf("metric %s: %s", m.Name, err)
+				}
+				var sum float64
+				if m.Sum != "" {
+					if sum, err = float(m.Sum); err != nil {
+						return fmt.Errorf("metric %s: %s", m.Name, err)
+					}
+				}
+				histograms[i].add(labelValues, bound, uint64(value), sum)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	for i, h := range histograms {
+		if h != nil {
+			h.collect(ch, s.descs[i])
+		}
+	}
+	return nil
+}
+
+// customHistograms accumulates the bucket rows of a histogram per label set.
+type customHistograms struct {
+	keys   []string
+	series map[string]*customHistogram
+}
+
+type customHistogram struct {
+	labelValues []string
+	counts      map[float64]uint64
+	sum         float64
+}
+
+func newCustomHistograms() *customHistograms {
+	return &customHistograms{series: map[string]*customHistogram{}}
+}
+
+func (h *customHistograms) add(labelValues []string, bound float64, count uint64, sum float64) {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := h.series[key]
+	if !ok {
+		s = &customHistogram{labelValues: labelValues, counts: map[float64]uint64{}}
+		h.series[key] = s
+		h.keys = append(h.keys, key)
+	}
+	s.counts[bound] += count
+	s.sum += sum
+}
+
+func (h *customHistograms) collect(ch chan<- prometheus.Metric, desc *prometheus.Desc) {
+	for _, key := range h.keys {
+		s := h.series[key]
+		bounds := make([]float64, 0, len(s.counts))
+		for bound := range s.counts {
+			bounds = append(bounds, bound)
+		}
+		sort.Float64s(bounds)
+
+		var count uint64
+		buckets := make(map[float64]uint64, len(bounds))
+		for _, bound := range bounds {
+			count += s.counts[bound]
+			buckets[bound] = count
+		}
+		ch <- prometheus.MustNewConstHistogram(desc, count, s.sum, buckets, s.labelValues...)
+	}
+}
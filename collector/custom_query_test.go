@@ -0,0 +1,226 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const customQueryYAML = `
+queries:
+  - name: table_rows
+    query: SELECT fq_name, node_id, fixed_elem_count, tot_commits FROM ndbinfo.memory_per_fragment
+    min_version: 5.10
+    timeout: 2s
+    min_interval: 1m
+    metrics:
+      - name: ndb_table_rows
+        help: Rows per table and node.
+        type: gauge
+        labels: [fq_name, node_id]
+        value: fixed_elem_count
+      - name: ndb_table_commits_total
+        help: Commits per table and node.
+        type: counter
+        labels: [fq_name, node_id]
+        value: tot_commits
+  - name: wait_times
+    query: SELECT node_id, upper_bound, count FROM ndbinfo.wait_stats
+    metrics:
+      - name: ndb_wait_seconds
+        help: Wait times.
+        type: histogram
+        labels: [node_id]
+        bucket: upper_bound
+        value: count
+`
+
+func loadCustomQueries(t *testing.T, content string) error {
+	f, err := ioutil.TempFile("", "custom-queries-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := kingpin.CommandLine.Parse([]string{"--collect.custom_query.file", f.Name()}); err != nil {
+		t.Fatal(err)
+	}
+	return LoadCustomQueries()
+}
+
+// collectScraper runs the scraper and returns its metrics.
+func collectScraper(scraper Scraper, db *sql.DB) ([]MetricResult, error) {
+	ch := make(chan prometheus.Metric)
+	var err error
+	go func() {
+		err = scraper.Scrape(context.Background(), db, ch)
+		close(ch)
+	}()
+	var got []MetricResult
+	for m := range ch {
+		got = append(got, readMetric(m))
+	}
+	return got, err
+}
+
+func TestLoadCustomQueries(t *testing.T) {
+	defer func() {
+		kingpin.CommandLine.Parse([]string{})
+		LoadCustomQueries()
+	}()
+
+	convey.Convey("Valid queries", t, func() {
+		convey.So(loadCustomQueries(t, customQueryYAML), convey.ShouldBeNil)
+
+		scrapers := expandScrapers([]Scraper{ScrapeGlobalStatus{}, ScrapeCustomQueries{}})
+		convey.So(scrapers, convey.ShouldHaveLength, 3)
+		convey.So(scrapers[1].Name(), convey.ShouldEqual, "custom_query.table_rows")
+		convey.So(scrapers[1].Version(), convey.ShouldResemble, MySQLVersion{5, 10})
+		convey.So(scrapers[2].Version(), convey.ShouldResemble, MySQLVersion{})
+		convey.So(scraperMinInterval(scrapers[1]), convey.ShouldEqual, time.Minute)
+		convey.So(scrapers[2].Name(), convey.ShouldEqual, "custom_query.wait_times")
+		convey.So(scraperMinInterval(scrapers[2]), convey.ShouldEqual, 0)
+	})
+
+	errors := []struct {
+		name, content, err string
+	}{
+		{"unknown field", "queries: [{name: a, qeury: SELECT 1}]", "field qeury not found"},
+		{"duplicate query", "queries: [{name: a, query: SELECT 1, metrics: [{name: a, type: gauge, value: x}]}, {name: a, query: SELECT 1}]", "query a: duplicate name"},
+		{"no metrics", "queries: [{name: a, query: SELECT 1}]", "query a: no metrics"},
+		{"invalid metric name", "queries: [{name: a, query: SELECT 1, metrics: [{name: a-b, type: gauge, value: x}]}]", "metric a-b: invalid metric name"},
+		{"invalid label", "queries: [{name: a, query: SELECT 1, metrics: [{name: a, type: gauge, value: x, labels: [a.b]}]}]", `invalid label name "a.b"`},
+		{"unknown type", "queries: [{name: a, query: SELECT 1, metrics: [{name: a, type: summary, value: x}]}]", `unknown type "summary"`},
+		{"invalid min_version", "queries: [{name: a, query: SELECT 1, min_version: 5.7.1, metrics: [{name: a, type: gauge, value: x}]}]", `query a: invalid MySQL version "5.7.1"`},
+		{"histogram without bucket", "queries: [{name: a, query: SELECT 1, metrics: [{name: a, type: histogram, value: x}]}]", "bucket is required"},
+	}
+	convey.Convey("Invalid queries keep the loaded ones", t, func() {
+		for _, e := range errors {
+			err := loadCustomQueries(t, e.content)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, e.err)
+			convey.So(ScrapeCustomQueries{}.Scrapers(), convey.ShouldHaveLength, 2)
+		}
+	})
+}
+
+func TestScrapeCustomQuery(t *testing.T) {
+	defer func() {
+		kingpin.CommandLine.Parse([]string{})
+		LoadCustomQueries()
+	}()
+	if err := loadCustomQueries(t, customQueryYAML); err != nil {
+		t.Fatal(err)
+	}
+	scrapers := ScrapeCustomQueries{}.Scrapers()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	convey.Convey("Gauges and counters", t, func() {
+		mock.ExpectQuery(sanitizeQuery("SELECT fq_name, node_id, fixed_elem_count, tot_commits FROM ndbinfo.memory_per_fragment")).
+			WillReturnRows(sqlmock.NewRows([]string{"fq_name", "node_id", "fixed_elem_count", "tot_commits"}).
+				AddRow("test/def/t1", 1, 10, 100).
+				AddRow("test/def/t1", 2, 12, 120))
+
+		got, err := collectScraper(scrapers[0], db)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			{labels: labelMap{"fq_name": "test/def/t1", "node_id": "1"}, value: 10, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"fq_name": "test/def/t1", "node_id": "1"}, value: 100, metricType: dto.MetricType_COUNTER},
+			{labels: labelMap{"fq_name": "test/def/t1", "node_id": "2"}, value: 12, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"fq_name": "test/def/t1", "node_id": "2"}, value: 120, metricType: dto.MetricType_COUNTER},
+		})
+	})
+
+	convey.Convey("Histograms", t, func() {
+		mock.ExpectQuery(sanitizeQuery("SELECT node_id, upper_bound, count FROM ndbinfo.wait_stats")).
+			WillReturnRows(sqlmock.NewRows([]string{"node_id", "upper_bound", "count"}).
+				AddRow(1, 0.1, 5).
+				AddRow(1, 0.01, 10).
+				AddRow(2, 0.01, 1))
+
+		ch := make(chan prometheus.Metric)
+		go func() {
+			if err := scrapers[1].Scrape(context.Background(), db, ch); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+			close(ch)
+		}()
+
+		got := &dto.Metric{}
+		convey.So((<-ch).Write(got), convey.ShouldBeNil)
+		convey.So(got.GetHistogram().GetSampleCount(), convey.ShouldEqual, 15)
+		buckets := got.GetHistogram().GetBucket()
+		convey.So(buckets, convey.ShouldHaveLength, 2)
+		convey.So(buckets[0].GetUpperBound(), convey.ShouldEqual, 0.01)
+		convey.So(buckets[0].GetCumulativeCount(), convey.ShouldEqual, 10)
+		convey.So(buckets[1].GetCumulativeCount(), convey.ShouldEqual, 15)
+
+		convey.So((<-ch).Write(got), convey.ShouldBeNil)
+		convey.So(got.GetHistogram().GetSampleCount(), convey.ShouldEqual, 1)
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Several rows with the same labels", t, func() {
+		mock.ExpectQuery(sanitizeQuery("SELECT fq_name, node_id, fixed_elem_count, tot_commits FROM ndbinfo.memory_per_fragment")).
+			WillReturnRows(sqlmock.NewRows([]string{"fq_name", "node_id", "fixed_elem_count", "tot_commits"}).
+				AddRow("test/def/t1", 1, 10, 100).
+				AddRow("test/def/t1", 1, 12, 120))
+
+		got, err := collectScraper(scrapers[0], db)
+		convey.So(err, convey.ShouldBeError, "metric ndb_table_rows: several rows for labels [test/def/t1 1]")
+		convey.So(got, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Negative histogram counts", t, func() {
+		mock.ExpectQuery(sanitizeQuery("SELECT node_id, upper_bound, count FROM ndbinfo.wait_stats")).
+			WillReturnRows(sqlmock.NewRows([]string{"node_id", "upper_bound", "count"}).AddRow(1, 0.1, -5))
+
+		_, err := collectScraper(scrapers[1], db)
+		convey.So(err, convey.ShouldBeError, `metric ndb_wait_seconds: negative value -5 in column "count"`)
+	})
+
+	convey.Convey("Missing column", t, func() {
+		mock.ExpectQuery(sanitizeQuery("SELECT node_id, upper_bound, count FROM ndbinfo.wait_stats")).
+			WillReturnRows(sqlmock.NewRows([]string{"node_id", "count"}).AddRow(1, 5))
+
+		_, err := collectScraper(scrapers[1], db)
+		convey.So(err, convey.ShouldBeError, `metric ndb_wait_seconds: column "upper_bound" not in result`)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
@@ -46,8 +46,8 @@ func (ScrapeEngineInnodbStatus) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeEngineInnodbStatus) Version() float64 {
-	return 5.1
+func (ScrapeEngineInnodbStatus) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -44,8 +44,8 @@ func (ScrapeEngineTokudbStatus) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeEngineTokudbStatus) Version() float64 {
-	return 5.6
+func (ScrapeEngineTokudbStatus) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -16,9 +16,6 @@ package collector
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -37,18 +34,12 @@ const (
 
 // SQL queries and parameters.
 const (
-	versionQuery = `SELECT @@version`
-
 	// System variable params formatting.
 	// See: https://github.com/go-sql-driver/mysql#system-variables
 	sessionSettingsParam = `log_slow_filter=%27tmp_table_on_disk,filesort_on_disk%27`
 	timeoutParam         = `lock_wait_timeout=%d`
 )
 
-var (
-	versionRE = regexp.MustCompile(`^\d+\.\d+`)
-)
-
 // Tunable flags.
 var (
 	exporterLockTimeout = kingpin.Flag(
@@ -59,6 +50,14 @@ var (
 		"exporter.log_slow_filter",
 		"Add a log_slow_filter to avoid slow query logging of scrapes. NOTE: Not supported by Oracle MySQL.",
 	).Default("false").Bool()
+	exporterScrapeParallelism = kingpin.Flag(
+		"exporter.scrape_parallelism",
+		"Maximum number of collectors running at the same time, 1 to run them one after another. Defaults to exporter.max_open_conns.",
+	).Default("0").Int()
+	exporterLongRunningCollectors = kingpin.Flag(
+		"exporter.long_running_collectors",
+		"Comma separated collectors, e.g. ndbinfo.cluster_operations, run one after another on an additional connection so they don't hold up the others.",
+	).Default("").String()
 )
 
 // Metric descriptors.
@@ -68,6 +67,21 @@ var (
 		"Collector time duration.",
 		[]string{"collector"}, nil,
 	)
+	scrapeQueueWaitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "collector_queue_wait_seconds"),
+		"Time a collector waited for a free worker before it ran.",
+		[]string{"collector"}, nil,
+	)
+	connectionReconnectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "connection_reconnects_total"),
+		"Number of times the exporter reconnected to MySQL after losing the connection.",
+		nil, nil,
+	)
+	connectionAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "connection_age_seconds"),
+		"Time since the exporter's connection pool last became connected to MySQL, 0 when disconnected.",
+		nil, nil,
+	)
 )
 
 // Verify if Exporter implements prometheus.Collector
@@ -76,33 +90,37 @@ var _ prometheus.Collector = (*Exporter)(nil)
 // Exporter collects MySQL metrics. It implements prometheus.Collector.
 type Exporter struct {
 	ctx      context.Context
-	dsn      string
+	pool     *Pool
 	scrapers []Scraper
 	metrics  Metrics
-}
-
-// New returns a new MySQL exporter for the provided DSN.
-func New(ctx context.Context, dsn string, metrics Metrics, scrapers []Scraper) *Exporter {
-	// Setup extra params for the DSN, default to having a lock timeout.
-	dsnParams := []string{fmt.Sprintf(timeoutParam, *exporterLockTimeout)}
-
-	if *slowLogFilter {
-		dsnParams = append(dsnParams, sessionSettingsParam)
-	}
 
-	if strings.Contains(dsn, "?") {
-		dsn = dsn + "&"
-	} else {
-		dsn = dsn + "?"
-	}
-	dsn += strings.Join(dsnParams, "&")
+	// constLabels are added to all metrics, and roleLabel with the roles of the instance if set.
+	constLabels prometheus.Labels
+	roleLabel   string
+}
 
-	return &Exporter{
+// New returns a new MySQL exporter scraping through the provided connection pool.
+// The labels of the exporter.const_label and exporter.role_label flags are added
+// to all its metrics.
+func New(ctx context.Context, pool *Pool, metrics Metrics, scrapers []Scraper) *Exporter {
+	e := &Exporter{
 		ctx:      ctx,
-		dsn:      dsn,
+		pool:     pool,
 		scrapers: scrapers,
 		metrics:  metrics,
 	}
+	e.SetConstLabels(prometheus.Labels(exporterConstLabels), *exporterRoleLabel)
+	return e
+}
+
+// SetConstLabels replaces the labels added to all metrics. If roleLabel is not
+// empty, a label of that name carries the comma separated roles of the instance.
+func (e *Exporter) SetConstLabels(labels prometheus.Labels, roleLabel string) {
+	e.constLabels = prometheus.Labels{}
+	for name, value := range labels {
+		e.constLabels[name] = value
+	}
+	e.roleLabel = roleLabel
 }
 
 // Describe implements prometheus.Collector.
@@ -110,39 +128,65 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.metrics.TotalScrapes.Desc()
 	ch <- e.metrics.Error.Desc()
 	e.metrics.ScrapeErrors.Describe(ch)
+	e.metrics.ScrapeTimeouts.Describe(ch)
 	ch <- e.metrics.MySQLUp.Desc()
 }
 
 // Collect implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.scrape(e.ctx, ch)
+	if len(e.constLabels) == 0 && e.roleLabel == "" {
+		e.collect(ch, nil)
+		return
+	}
+
+	in := make(chan prometheus.Metric)
+	roles := make(chan []string, 1)
+	done := make(chan struct{})
+	go func() {
+		e.forwardLabelled(in, ch, roles)
+		close(done)
+	}()
+	e.collect(in, roles)
+	close(in)
+	<-done
+}
+
+// collect sends all metrics to ch, and the roles of the instance to roles if not nil.
+func (e *Exporter) collect(ch chan<- prometheus.Metric, roles chan<- []string) {
+	e.scrape(e.ctx, ch, roles)
 
 	ch <- e.metrics.TotalScrapes
 	ch <- e.metrics.Error
 	e.metrics.ScrapeErrors.Collect(ch)
+	e.metrics.ScrapeTimeouts.Collect(ch)
 	ch <- e.metrics.MySQLUp
 }
 
-func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
+func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric, roles chan<- []string) {
 	e.metrics.TotalScrapes.Inc()
+	e.metrics.Error.Set(0)
 	var err error
 
-	scrapeTime := time.Now()
-	db, err := sql.Open("mysql", e.dsn)
-	if err != nil {
-		log.Errorln("Error opening connection to database:", err)
-		e.metrics.Error.Set(1)
-		return
-	}
-	defer db.Close()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	// By design exporter should use maximum one connection per request.
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
-	// Set max lifetime for a connection.
-	db.SetConnMaxLifetime(1 * time.Minute)
+	scrapers := expandScrapers(e.scrapers)
+	// Standalone scrapers don't need mysqld, so run them even if it is down.
+	for _, scraper := range scrapers {
+		if isStandalone(scraper) {
+			wg.Add(1)
+			go func(scraper Scraper) {
+				defer wg.Done()
+				e.runScraper(ctx, nil, scraper, ch)
+			}(scraper)
+		}
+	}
 
-	if err := db.PingContext(ctx); err != nil {
+	scrapeTime := time.Now()
+	db, err := e.pool.Get(ctx)
+	ch <- prometheus.MustNewConstMetric(connectionReconnectsDesc, prometheus.CounterValue, float64(e.pool.Reconnects()))
+	ch <- prometheus.MustNewConstMetric(connectionAgeDesc, prometheus.GaugeValue, e.pool.ConnectionAge().Seconds())
+	if err != nil {
 		log.Errorln("Error pinging mysqld:", err)
 		e.metrics.MySQLUp.Set(0)
 		e.metrics.Error.Set(1)
@@ -150,52 +194,181 @@ func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	}
 
 	e.metrics.MySQLUp.Set(1)
-	e.metrics.Error.Set(0)
 
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), "connection")
 
-	version := getMySQLVersion(db)
-	var wg sync.WaitGroup
-	defer wg.Wait()
-	for _, scraper := range e.scrapers {
-		if version < scraper.Version() {
+	// Only one SQL node of the cluster reports ndbinfo metrics in designated reporter mode.
+	ndbinfoReporter := true
+	if *ndbinfoDesignatedReporter {
+		if ndbinfoReporter, err = isNdbinfoReporter(ctx, db); err != nil {
+			// Rather report twice than not at all.
+			log.Errorln("Error determining ndbinfo reporter:", err)
+			ndbinfoReporter = true
+		}
+		var value float64
+		if ndbinfoReporter {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ndbinfoReporterDesc, prometheus.GaugeValue, value)
+	}
+
+	info, err := e.pool.ServerInfo(ctx, db)
+	if err != nil {
+		// Unknown versions run all scrapers, as recent servers would.
+		log.Errorln("Error detecting the server version:", err)
+	} else {
+		ch <- info.versionInfoMetric()
+	}
+	dbCtx := withPool(withServerInfo(ctx, info), e.pool)
+
+	// Detecting the roles takes several queries, only do it when they are used.
+	var held []string
+	if e.roleLabel != "" || *exporterInstanceRole {
+		held = e.pool.InstanceRoles(dbCtx, db, info)
+	}
+	if *exporterInstanceRole {
+		for _, m := range instanceRoleMetrics(held) {
+			ch <- m
+		}
+	}
+	if roles != nil {
+		roles <- held
+	}
+
+	// Only look at the NDB side if NDB specific scrapers are enabled.
+	var ndb *ndbServer
+	for _, scraper := range scrapers {
+		if _, ok := scraper.(NdbScraper); ok {
+			if ndb, err = e.pool.NdbServer(dbCtx, db, info); err != nil {
+				log.Errorln("Error reading the ndbinfo tables:", err)
+			}
+			break
+		}
+	}
+	var queue, longRunning []Scraper
+	for _, scraper := range scrapers {
+		if isStandalone(scraper) || !isApplicable(scraper, info) {
+			continue
+		}
+		if !ndbinfoReporter && isNdbinfoScraper(scraper) {
 			continue
 		}
+		if ndbScraper, ok := scraper.(NdbScraper); ok {
+			label := "collect." + scraper.Name()
+			if !ndb.supports(ndbScraper) {
+				log.Debugf("Skipping %s, it requires NDB %s and ndbinfo tables %s", label, ndbScraper.NdbVersion(), strings.Join(ndbScraper.NdbinfoTables(), ", "))
+				ch <- prometheus.MustNewConstMetric(scraperUnsupportedDesc, prometheus.GaugeValue, 1, label)
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(scraperUnsupportedDesc, prometheus.GaugeValue, 0, label)
+		}
+
+		if isLongRunning(scraper) {
+			longRunning = append(longRunning, scraper)
+		} else {
+			queue = append(queue, scraper)
+		}
+	}
+	e.runQueue(dbCtx, db, queue, scrapeParallelism(), ch, &wg)
+	if len(longRunning) == 0 {
+		return
+	}
+	longRunningDB, err := e.pool.LongRunning()
+	if err != nil {
+		log.Errorln("Error opening the connection of the long running collectors:", err)
+		e.metrics.Error.Set(1)
+		return
+	}
+	e.runQueue(dbCtx, longRunningDB, longRunning, 1, ch, &wg)
+}
 
+// scrapeParallelism returns the number of collectors that may run at the same time.
+func scrapeParallelism() int {
+	if *exporterScrapeParallelism > 0 {
+		return *exporterScrapeParallelism
+	}
+	if *exporterMaxOpenConns > 0 {
+		return *exporterMaxOpenConns
+	}
+	return 1
+}
+
+// isLongRunning returns whether the scraper is listed in exporter.long_running_collectors.
+func isLongRunning(scraper Scraper) bool {
+	for _, name := range strings.Split(*exporterLongRunningCollectors, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(name), "collect.") == scraper.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// runQueue runs the scrapers in order on at most parallelism goroutines, so
+// their durations measure the queries instead of the wait for a connection.
+// The time spent waiting for a worker is reported separately.
+func (e *Exporter) runQueue(ctx context.Context, db *sql.DB, scrapers []Scraper, parallelism int, ch chan<- prometheus.Metric, wg *sync.WaitGroup) {
+	queued := time.Now()
+	queue := make(chan Scraper, len(scrapers))
+	for _, scraper := range scrapers {
+		queue <- scraper
+	}
+	close(queue)
+
+	if parallelism > len(scrapers) {
+		parallelism = len(scrapers)
+	}
+	for i := 0; i < parallelism; i++ {
 		wg.Add(1)
-		go func(scraper Scraper) {
+		go func() {
 			defer wg.Done()
-			label := "collect." + scraper.Name()
-			scrapeTime := time.Now()
-			if err := scraper.Scrape(ctx, db, ch); err != nil {
-				log.Errorln("Error scraping for "+label+":", err)
-				e.metrics.ScrapeErrors.WithLabelValues(label).Inc()
-				e.metrics.Error.Set(1)
+			for scraper := range queue {
+				ch <- prometheus.MustNewConstMetric(scrapeQueueWaitDesc, prometheus.GaugeValue, time.Since(queued).Seconds(), "collect."+scraper.Name())
+				e.runScraper(ctx, db, scraper, ch)
 			}
-			ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
-		}(scraper)
+		}()
 	}
 }
 
-func getMySQLVersion(db *sql.DB) float64 {
-	var versionStr string
-	var versionNum float64
-	if err := db.QueryRow(versionQuery).Scan(&versionStr); err == nil {
-		versionNum, _ = strconv.ParseFloat(versionRE.FindString(versionStr), 64)
+// runScraper runs a single scraper and records its duration and errors. The
+// scraper gets its own deadline, so a slow one does not cancel the others. The
+// metrics it sent before timing out are kept.
+func (e *Exporter) runScraper(ctx context.Context, db *sql.DB, scraper Scraper, ch chan<- prometheus.Metric) {
+	label := "collect." + scraper.Name()
+	scrapeTime := time.Now()
+	if timeout := scraperTimeout(scraper); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	// If we can't match/parse the version, set it some big value that matches all versions.
-	if versionNum == 0 {
-		versionNum = 999
+	var err error
+	if interval := scraperMinInterval(scraper); interval > 0 {
+		err = e.pool.cache.scrape(ctx, db, scraper, interval, ch)
+	} else {
+		err = scraper.Scrape(ctx, db, ch)
 	}
-	return versionNum
+	success := 1.0
+	if err != nil {
+		success = 0
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Errorf("Timeout scraping for %s after %s: %s", label, time.Since(scrapeTime), err)
+			e.metrics.ScrapeTimeouts.WithLabelValues(label).Inc()
+		} else {
+			log.Errorln("Error scraping for "+label+":", err)
+		}
+		e.metrics.ScrapeErrors.WithLabelValues(label).Inc()
+		e.metrics.Error.Set(1)
+	}
+	ch <- prometheus.MustNewConstMetric(scraperSuccessDesc, prometheus.GaugeValue, success, label)
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
 }
 
 // Metrics represents exporter metrics which values can be carried between http requests.
 type Metrics struct {
-	TotalScrapes prometheus.Counter
-	ScrapeErrors *prometheus.CounterVec
-	Error        prometheus.Gauge
-	MySQLUp      prometheus.Gauge
+	TotalScrapes   prometheus.Counter
+	ScrapeErrors   *prometheus.CounterVec
+	ScrapeTimeouts *prometheus.CounterVec
+	Error          prometheus.Gauge
+	MySQLUp        prometheus.Gauge
 }
 
 // NewMetrics creates new Metrics instance.
@@ -214,6 +387,12 @@ func NewMetrics() Metrics {
 			Name:      "scrape_errors_total",
 			Help:      "Total number of times an error occurred scraping a MySQL.",
 		}, []string{"collector"}),
+		ScrapeTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "collector_timeouts_total",
+			Help:      "Total number of times a collector was aborted by its timeout or the scrape timeout.",
+		}, []string{"collector"}),
 		Error: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
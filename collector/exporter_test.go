@@ -16,11 +16,15 @@ package collector
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 const dsn = "root@/mysql"
@@ -32,7 +36,7 @@ func TestExporter(t *testing.T) {
 
 	exporter := New(
 		context.Background(),
-		dsn,
+		NewPool(dsn),
 		NewMetrics(),
 		[]Scraper{
 			ScrapeGlobalStatus{},
@@ -65,7 +69,7 @@ func TestExporter(t *testing.T) {
 	})
 }
 
-func TestGetMySQLVersion(t *testing.T) {
+func TestGetServerInfo(t *testing.T) {
 	if testing.Short() {
 		t.Skip("-short is passed, skipping test")
 	}
@@ -75,6 +79,164 @@ func TestGetMySQLVersion(t *testing.T) {
 		convey.So(err, convey.ShouldBeNil)
 		defer db.Close()
 
-		convey.So(getMySQLVersion(db), convey.ShouldBeBetweenOrEqual, 5.5, 10.3)
+		info, err := getServerInfo(context.Background(), db)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(info.Major, convey.ShouldBeBetweenOrEqual, 5, 10)
+	})
+}
+
+// slowScraper sends one metric and then blocks until its context is done.
+type slowScraper struct{}
+
+func (slowScraper) Name() string          { return "test.slow" }
+func (slowScraper) Help() string          { return "" }
+func (slowScraper) Version() MySQLVersion { return MySQLVersion{5, 1} }
+
+func (slowScraper) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(countingDesc, prometheus.GaugeValue, 1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunScraperTimeout(t *testing.T) {
+	// Repeated flags accumulate over kingpin.CommandLine.Parse calls.
+	defer func() {
+		for name := range scraperTimeouts {
+			delete(scraperTimeouts, name)
+		}
+	}()
+
+	run := func(e *Exporter, scraper Scraper) (partial []prometheus.Metric, success float64) {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			e.runScraper(context.Background(), nil, scraper, ch)
+			close(ch)
+		}()
+		for m := range ch {
+			switch m.Desc() {
+			case scraperSuccessDesc:
+				success = readMetric(m).value
+			case countingDesc:
+				partial = append(partial, m)
+			}
+		}
+		return partial, success
+	}
+
+	convey.Convey("Scrapers time out on their own deadline", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{
+			"--collect.timeout", "1h",
+			"--collect.scraper_timeout", "collect.test.slow=10ms",
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraperTimeout(slowScraper{}), convey.ShouldEqual, 10*time.Millisecond)
+		convey.So(scraperTimeout(ScrapeGlobalStatus{}), convey.ShouldEqual, time.Hour)
+
+		e := New(context.Background(), NewPool(dsn), NewMetrics(), nil)
+		partial, success := run(e, slowScraper{})
+		convey.So(partial, convey.ShouldHaveLength, 1)
+		convey.So(success, convey.ShouldEqual, 0)
+		timeouts := readMetric(e.metrics.ScrapeTimeouts.WithLabelValues("collect.test.slow"))
+		convey.So(timeouts.value, convey.ShouldEqual, 1)
+
+		_, success = run(e, &countingScraper{})
+		convey.So(success, convey.ShouldEqual, 1)
+		timeouts = readMetric(e.metrics.ScrapeTimeouts.WithLabelValues("collect.test.counting"))
+		convey.So(timeouts.value, convey.ShouldEqual, 0)
+	})
+
+	convey.Convey("Failing scrapers are not counted as timeouts", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(scraperTimeout(ScrapeGlobalStatus{}), convey.ShouldEqual, 0)
+
+		e := New(context.Background(), NewPool(dsn), NewMetrics(), nil)
+		_, success := run(e, &countingScraper{err: context.Canceled})
+		convey.So(success, convey.ShouldEqual, 0)
+		timeouts := readMetric(e.metrics.ScrapeTimeouts.WithLabelValues("collect.test.counting"))
+		convey.So(timeouts.value, convey.ShouldEqual, 0)
+	})
+}
+
+// concurrentScraper records the highest number of its runs overlapping in time.
+type concurrentScraper struct {
+	name    string
+	mu      *sync.Mutex
+	running *int
+	max     *int
+}
+
+func (s concurrentScraper) Name() string          { return s.name }
+func (s concurrentScraper) Help() string          { return "" }
+func (s concurrentScraper) Version() MySQLVersion { return MySQLVersion{5, 1} }
+
+func (s concurrentScraper) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	s.mu.Lock()
+	*s.running++
+	if *s.running > *s.max {
+		*s.max = *s.running
+	}
+	s.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	s.mu.Lock()
+	*s.running--
+	s.mu.Unlock()
+	return nil
+}
+
+func TestRunQueue(t *testing.T) {
+	run := func(parallelism int) (maximum int, waits labelMap) {
+		var (
+			mu       sync.Mutex
+			running  int
+			scrapers []Scraper
+		)
+		for i := 0; i < 6; i++ {
+			scrapers = append(scrapers, concurrentScraper{name: fmt.Sprintf("test.concurrent%d", i), mu: &mu, running: &running, max: &maximum})
+		}
+		e := New(context.Background(), NewPool(dsn), NewMetrics(), nil)
+		ch := make(chan prometheus.Metric)
+		go func() {
+			var wg sync.WaitGroup
+			e.runQueue(context.Background(), nil, scrapers, parallelism, ch, &wg)
+			wg.Wait()
+			close(ch)
+		}()
+		waits = labelMap{}
+		for m := range ch {
+			if m.Desc() == scrapeQueueWaitDesc {
+				got := readMetric(m)
+				waits[got.labels["collector"]] = fmt.Sprint(got.value > 0)
+			}
+		}
+		return maximum, waits
+	}
+
+	convey.Convey("Serial mode", t, func() {
+		maximum, waits := run(1)
+		convey.So(maximum, convey.ShouldEqual, 1)
+		convey.So(waits, convey.ShouldHaveLength, 6)
+		// The last scraper waited for the five before it.
+		convey.So(waits["collect.test.concurrent5"], convey.ShouldEqual, "true")
+	})
+
+	convey.Convey("Bounded parallelism", t, func() {
+		maximum, _ := run(3)
+		convey.So(maximum, convey.ShouldEqual, 3)
+		maximum, _ = run(20)
+		convey.So(maximum, convey.ShouldEqual, 6)
+	})
+
+	convey.Convey("Long running collectors", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{"--exporter.long_running_collectors", "collect.test.slow, info_schema.tables"})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(isLongRunning(slowScraper{}), convey.ShouldBeTrue)
+		convey.So(isLongRunning(ScrapeTableSchema{}), convey.ShouldBeTrue)
+		convey.So(isLongRunning(ScrapeGlobalStatus{}), convey.ShouldBeFalse)
+
+		_, err = kingpin.CommandLine.Parse([]string{"--exporter.max_open_conns", "4"})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(isLongRunning(slowScraper{}), convey.ShouldBeFalse)
+		convey.So(scrapeParallelism(), convey.ShouldEqual, 4)
 	})
 }
@@ -93,8 +93,8 @@ func (ScrapeGlobalStatus) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeGlobalStatus) Version() float64 {
-	return 5.1
+func (ScrapeGlobalStatus) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
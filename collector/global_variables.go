@@ -132,8 +132,8 @@ func (ScrapeGlobalVariables) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeGlobalVariables) Version() float64 {
-	return 5.1
+func (ScrapeGlobalVariables) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -147,9 +147,6 @@ func (ScrapeGlobalVariables) Scrape(ctx context.Context, db *sql.DB, ch chan<- p
 	var key string
 	var val sql.RawBytes
 	var textItems = map[string]string{
-		"innodb_version":         "",
-		"version":                "",
-		"version_comment":        "",
 		"wsrep_cluster_name":     "",
 		"wsrep_provider_options": "",
 	}
@@ -178,12 +175,7 @@ func (ScrapeGlobalVariables) Scrape(ctx context.Context, db *sql.DB, ch chan<- p
 		}
 	}
 
-	// mysql_version_info metric.
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prometheus.BuildFQName(namespace, "version", "info"), "MySQL version and distribution.",
-			[]string{"innodb_version", "version", "version_comment"}, nil),
-		prometheus.GaugeValue, 1, textItems["innodb_version"], textItems["version"], textItems["version_comment"],
-	)
+	// mysql_version_info is exported by the Exporter from the ServerInfo.
 
 	// mysql_galera_variables_info metric.
 	if textItems["wsrep_cluster_name"] != "" {
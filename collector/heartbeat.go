@@ -80,8 +80,8 @@ func (ScrapeHeartbeat) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeHeartbeat) Version() float64 {
-	return 5.1
+func (ScrapeHeartbeat) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -64,8 +64,8 @@ func (ScrapeAutoIncrementColumns) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeAutoIncrementColumns) Version() float64 {
-	return 5.1
+func (ScrapeAutoIncrementColumns) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
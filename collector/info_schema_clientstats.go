@@ -155,8 +155,8 @@ func (ScrapeClientStat) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeClientStat) Version() float64 {
-	return 5.5
+func (ScrapeClientStat) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -67,8 +67,8 @@ func (ScrapeFiles) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeFiles) Version() float64 {
-	return 5.1
+func (ScrapeFiles) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -71,8 +71,8 @@ func (ScrapeInnodbCmp) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeInnodbCmp) Version() float64 {
-	return 5.5
+func (ScrapeInnodbCmp) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -66,8 +66,8 @@ func (ScrapeInnodbCmpMem) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeInnodbCmpMem) Version() float64 {
-	return 5.5
+func (ScrapeInnodbCmpMem) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
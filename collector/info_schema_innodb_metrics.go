@@ -76,8 +76,8 @@ func (ScrapeInnodbMetrics) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeInnodbMetrics) Version() float64 {
-	return 5.6
+func (ScrapeInnodbMetrics) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
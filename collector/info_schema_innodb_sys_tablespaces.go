@@ -67,8 +67,13 @@ func (ScrapeInfoSchemaInnodbTablespaces) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeInfoSchemaInnodbTablespaces) Version() float64 {
-	return 5.7
+func (ScrapeInfoSchemaInnodbTablespaces) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// Applicable before MySQL 8.0, which renamed the table to information_schema.innodb_tablespaces.
+func (ScrapeInfoSchemaInnodbTablespaces) Applicable(info ServerInfo) bool {
+	return info.AtLeast(5, 7) && (info.Flavor == FlavorMariaDB || !info.AtLeast(8, 0))
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -121,3 +126,4 @@ func (ScrapeInfoSchemaInnodbTablespaces) Scrape(ctx context.Context, db *sql.DB,
 
 // check interface
 var _ Scraper = ScrapeInfoSchemaInnodbTablespaces{}
+var _ ApplicableScraper = ScrapeInfoSchemaInnodbTablespaces{}
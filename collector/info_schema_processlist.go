@@ -171,8 +171,8 @@ func (ScrapeProcesslist) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeProcesslist) Version() float64 {
-	return 5.1
+func (ScrapeProcesslist) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -113,8 +113,8 @@ func (ScrapeQueryResponseTime) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeQueryResponseTime) Version() float64 {
-	return 5.5
+func (ScrapeQueryResponseTime) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -66,8 +66,8 @@ func (ScrapeSchemaStat) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeSchemaStat) Version() float64 {
-	return 5.1
+func (ScrapeSchemaStat) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
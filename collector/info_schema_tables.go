@@ -91,8 +91,8 @@ func (ScrapeTableSchema) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeTableSchema) Version() float64 {
-	return 5.1
+func (ScrapeTableSchema) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
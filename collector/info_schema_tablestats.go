@@ -66,8 +66,8 @@ func (ScrapeTableStat) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeTableStat) Version() float64 {
-	return 5.1
+func (ScrapeTableStat) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
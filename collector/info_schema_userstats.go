@@ -151,8 +151,8 @@ func (ScrapeUserStat) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeUserStat) Version() float64 {
-	return 5.1
+func (ScrapeUserStat) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -0,0 +1,139 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Detect the replication roles of the instance.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	instanceGroupMemberQuery = `
+		SELECT COUNT(*)
+		  FROM performance_schema.replication_group_members
+		  WHERE MEMBER_ID = @@server_uuid AND MEMBER_STATE = 'ONLINE'
+		`
+	instanceNdbBinlogQuery = `SELECT @@log_bin, @@ndb_log_bin`
+)
+
+// The roles are detected again after this long, e.g. to notice a failover.
+const instanceRoleCacheInterval = 30 * time.Second
+
+// Tunable flags.
+var (
+	exporterInstanceRole = kingpin.Flag(
+		"exporter.instance_role",
+		"Export the replication roles of the instance as mysql_instance_role.",
+	).Default("false").Bool()
+)
+
+// Instance roles.
+const (
+	// RoleSource has replicas connected.
+	RoleSource = "source"
+	// RoleReplica replicates from a source.
+	RoleReplica = "replica"
+	// RoleGroupMember is an online member of a replication group.
+	RoleGroupMember = "group_member"
+	// RoleNdbSource is an NDB Cluster SQL node writing the changes of the
+	// cluster to its binary log, i.e. the source side of an NDB replication channel.
+	RoleNdbSource = "ndb_source"
+	// RoleNdbReplica is an NDB Cluster SQL node applying changes from another cluster.
+	RoleNdbReplica = "ndb_replica"
+	// RoleStandalone has none of the other roles.
+	RoleStandalone = "standalone"
+)
+
+// instanceRoles lists all roles in the order they are reported.
+var instanceRoles = []string{RoleSource, RoleReplica, RoleGroupMember, RoleNdbSource, RoleNdbReplica, RoleStandalone}
+
+// Metric descriptors.
+var (
+	instanceRoleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "instance", "role"),
+		"Whether the instance has the replication role, an instance can have several.",
+		[]string{"role"}, nil,
+	)
+)
+
+// getInstanceRoles determines the roles of the instance. Checks that fail, e.g.
+// for lack of privileges, are treated as the role being absent.
+func getInstanceRoles(ctx context.Context, db *sql.DB, info ServerInfo) []string {
+	held := map[string]bool{}
+
+	// Try the both syntax for MySQL/Percona and MariaDB, as ScrapeSlaveStatus does.
+	for _, query := range slaveStatusQueries {
+		if rows, err := db.QueryContext(ctx, query); err == nil {
+			held[RoleReplica] = rows.Next()
+			rows.Close()
+			break
+		}
+	}
+	if rows, err := db.QueryContext(ctx, slaveHostsQuery); err != nil {
+		log.Debugln("Error checking for replicas:", err)
+	} else {
+		held[RoleSource] = rows.Next()
+		rows.Close()
+	}
+	if info.Flavor != FlavorMariaDB && info.AtLeast(5, 7) {
+		var members int
+		if err := db.QueryRowContext(ctx, instanceGroupMemberQuery).Scan(&members); err != nil {
+			log.Debugln("Error checking for group replication:", err)
+		}
+		held[RoleGroupMember] = members > 0
+	}
+	if info.NdbVersion != nil {
+		var logBin, ndbLogBin bool
+		if err := db.QueryRowContext(ctx, instanceNdbBinlogQuery).Scan(&logBin, &ndbLogBin); err != nil {
+			log.Debugln("Error checking for NDB binary logging:", err)
+		}
+		held[RoleNdbSource] = logBin && ndbLogBin
+		held[RoleNdbReplica] = held[RoleReplica]
+	}
+
+	var roles []string
+	for _, role := range instanceRoles {
+		if held[role] {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{RoleStandalone}
+	}
+	return roles
+}
+
+// instanceRoleMetrics returns mysql_instance_role for all roles, 1 for those held.
+func instanceRoleMetrics(roles []string) []prometheus.Metric {
+	held := map[string]bool{}
+	for _, role := range roles {
+		held[role] = true
+	}
+	metrics := make([]prometheus.Metric, 0, len(instanceRoles))
+	for _, role := range instanceRoles {
+		var value float64
+		if held[role] {
+			value = 1
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(instanceRoleDesc, prometheus.GaugeValue, value, role))
+	}
+	return metrics
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGetInstanceRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	convey.Convey("NDB Cluster replica writing its own binary log", t, func() {
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[0])).WillReturnError(fmt.Errorf("syntax error"))
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[1])).
+			WillReturnRows(sqlmock.NewRows([]string{"Master_Host", "Slave_IO_Running"}).AddRow("10.0.0.1", "Yes"))
+		mock.ExpectQuery(sanitizeQuery(slaveHostsQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"Server_id", "Host"}))
+		mock.ExpectQuery(sanitizeQuery(instanceGroupMemberQuery)).
+			WillReturnError(fmt.Errorf("Table 'performance_schema.replication_group_members' doesn't exist"))
+		mock.ExpectQuery(sanitizeQuery(instanceNdbBinlogQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"@@log_bin", "@@ndb_log_bin"}).AddRow(1, 1))
+
+		info := ParseServerInfo("5.7.28-ndb-7.6.12-cluster-gpl", "", "", "ndb-7.6.12")
+		roles := getInstanceRoles(context.Background(), db, info)
+		convey.So(roles, convey.ShouldResemble, []string{RoleReplica, RoleNdbSource, RoleNdbReplica})
+
+		got := map[string]float64{}
+		for _, m := range instanceRoleMetrics(roles) {
+			r := readMetric(m)
+			got[r.labels["role"]] = r.value
+		}
+		convey.So(got, convey.ShouldResemble, map[string]float64{
+			RoleSource: 0, RoleReplica: 1, RoleGroupMember: 0, RoleNdbSource: 1, RoleNdbReplica: 1, RoleStandalone: 0,
+		})
+	})
+
+	convey.Convey("MySQL group member with replicas", t, func() {
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[0])).WillReturnError(fmt.Errorf("syntax error"))
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[1])).
+			WillReturnRows(sqlmock.NewRows([]string{"Master_Host"}))
+		mock.ExpectQuery(sanitizeQuery(slaveHostsQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"Server_id", "Host"}).AddRow(2, "replica1"))
+		mock.ExpectQuery(sanitizeQuery(instanceGroupMemberQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+
+		roles := getInstanceRoles(context.Background(), db, ParseServerInfo("8.0.21", "", "", ""))
+		convey.So(roles, convey.ShouldResemble, []string{RoleSource, RoleGroupMember})
+	})
+
+	convey.Convey("MariaDB without replication", t, func() {
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[0])).
+			WillReturnRows(sqlmock.NewRows([]string{"Connection_name"}))
+		mock.ExpectQuery(sanitizeQuery(slaveHostsQuery)).
+			WillReturnError(fmt.Errorf("Access denied"))
+
+		roles := getInstanceRoles(context.Background(), db, ParseServerInfo("10.3.27-MariaDB", "", "", ""))
+		convey.So(roles, convey.ShouldResemble, []string{RoleStandalone})
+	})
+
+	convey.Convey("Roles are cached by the pool", t, func() {
+		mock.ExpectQuery(sanitizeQuery(slaveStatusQueries[0])).
+			WillReturnRows(sqlmock.NewRows([]string{"Connection_name"}))
+		mock.ExpectQuery(sanitizeQuery(slaveHostsQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"Server_id", "Host"}))
+
+		pool := NewPool(dsn)
+		info := ParseServerInfo("10.3.27-MariaDB", "", "", "")
+		convey.So(pool.InstanceRoles(context.Background(), db, info), convey.ShouldResemble, []string{RoleStandalone})
+		convey.So(pool.InstanceRoles(context.Background(), db, info), convey.ShouldResemble, []string{RoleStandalone})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
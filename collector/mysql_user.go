@@ -111,8 +111,8 @@ func (ScrapeUser) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeUser) Version() float64 {
-	return 5.1
+func (ScrapeUser) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -0,0 +1,368 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape the NDB management server (ndb_mgmd) using its native text protocol.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Subsystem.
+const ndbMgm = "mgm"
+
+const (
+	ndbMgmStatusCommand = "get status"
+	ndbMgmStatusReply   = "node status"
+)
+
+// Tunable flags.
+var (
+	ndbMgmConnectString = kingpin.Flag(
+		"collect.ndb_mgm.connectstring",
+		"Comma separated list of NDB management servers (host:port) to query, the first reachable one is used.",
+	).Default("localhost:1186").String()
+	ndbMgmTimeout = kingpin.Flag(
+		"collect.ndb_mgm.timeout",
+		"Timeout for connecting to and reading from the NDB management server.",
+	).Default("5s").Duration()
+)
+
+// Metric descriptors.
+var (
+	ndbMgmUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "up"),
+		"Whether the NDB management server could be reached",
+		[]string{"address"}, nil,
+	)
+	ndbMgmNodeStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "node_status"),
+		"Status of each node as reported by the management server",
+		[]string{"nodeID", "nodeType", "status"}, nil,
+	)
+	ndbMgmNodeStartPhaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "node_start_phase"),
+		"Start phase of each data node, 0 when the node is not starting",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmNodeGroupDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "node_group"),
+		"Node group each data node belongs to",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmNodeVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "node_version_info"),
+		"NDB and MySQL version of each connected node",
+		[]string{"nodeID", "nodeType", "version", "mysqlVersion"}, nil,
+	)
+	ndbMgmNodeConnectCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "node_connect_count"),
+		"Number of times each node has connected to the cluster",
+		[]string{"nodeID", "nodeType"}, nil,
+	)
+	ndbMgmConnectedAPINodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "connected_api_nodes"),
+		"Number of API nodes (including SQL nodes) connected to the cluster",
+		nil, nil,
+	)
+)
+
+// ndbMgmNode holds the properties of one node from the `get status` reply.
+type ndbMgmNode struct {
+	id           uint64
+	nodeType     string
+	status       string
+	version      uint64
+	mysqlVersion uint64
+	startPhase   uint64
+	nodeGroup    uint64
+	connectCount uint64
+}
+
+// ScrapeNdbMgmStatus collects node status from the NDB management server.
+type ScrapeNdbMgmStatus struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbMgmStatus) Name() string {
+	return "ndb_mgm.status"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeNdbMgmStatus) Help() string {
+	return "Collect node status from the NDB management server using the management protocol"
+}
+
+// Version of MySQL from which scraper is available.
+func (ScrapeNdbMgmStatus) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
+}
+
+// Standalone reports that the scraper does not use the MySQL connection.
+func (ScrapeNdbMgmStatus) Standalone() bool {
+	return true
+}
+
+// Scrape collects data from the management server and sends it over channel as prometheus metric.
+// The database connection is not used.
+func (ScrapeNdbMgmStatus) Scrape(ctx context.Context, _ *sql.DB, ch chan<- prometheus.Metric) error {
+	var lastErr error
+	for _, address := range ndbMgmAddresses() {
+		nodes, err := ndbMgmGetStatus(ctx, address, *ndbMgmTimeout)
+		if err != nil {
+			log.Debugf("Error querying NDB management server %s: %s", address, err)
+			ch <- prometheus.MustNewConstMetric(ndbMgmUpDesc, prometheus.GaugeValue, 0, address)
+			lastErr = err
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(ndbMgmUpDesc, prometheus.GaugeValue, 1, address)
+		ndbMgmExportNodes(nodes, ch)
+		return nil
+	}
+	if lastErr == nil {
+		return fmt.Errorf("no NDB management server address configured")
+	}
+	return lastErr
+}
+
+func ndbMgmExportNodes(nodes []ndbMgmNode, ch chan<- prometheus.Metric) {
+	var connectedAPINodes float64
+	for _, node := range nodes {
+		nodeID := strconv.FormatUint(node.id, 10)
+		ch <- prometheus.MustNewConstMetric(
+			ndbMgmNodeStatusDesc, prometheus.GaugeValue, 1,
+			nodeID, node.nodeType, node.status)
+		ch <- prometheus.MustNewConstMetric(
+			ndbMgmNodeConnectCountDesc, prometheus.CounterValue, float64(node.connectCount),
+			nodeID, node.nodeType)
+		if node.version != 0 {
+			ch <- prometheus.MustNewConstMetric(
+				ndbMgmNodeVersionDesc, prometheus.GaugeValue, 1,
+				nodeID, node.nodeType, ndbMgmFormatVersion(node.version), ndbMgmFormatVersion(node.mysqlVersion))
+		}
+		switch node.nodeType {
+		case "NDB":
+			ch <- prometheus.MustNewConstMetric(
+				ndbMgmNodeStartPhaseDesc, prometheus.GaugeValue, float64(node.startPhase),
+				nodeID)
+			ch <- prometheus.MustNewConstMetric(
+				ndbMgmNodeGroupDesc, prometheus.GaugeValue, float64(node.nodeGroup),
+				nodeID)
+		case "API":
+			if node.status == "CONNECTED" {
+				connectedAPINodes++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(
+		ndbMgmConnectedAPINodesDesc, prometheus.GaugeValue, connectedAPINodes)
+}
+
+// ndbMgmGetStatus connects to the management server at address and issues `get status`.
+func ndbMgmGetStatus(ctx context.Context, address string, timeout time.Duration) ([]ndbMgmNode, error) {
+	var nodes []ndbMgmNode
+	err := ndbMgmCall(ctx, address, timeout, ndbMgmStatusCommand, nil, func(r *bufio.Reader) (err error) {
+		nodes, err = parseNdbMgmStatus(r)
+		return err
+	})
+	return nodes, err
+}
+
+// ndbMgmCall connects to the management server at address, sends command
+// with its "name: value" arguments and reads the reply with read.
+// The connection is closed when ctx is done or timeout has passed.
+func ndbMgmCall(ctx context.Context, address string, timeout time.Duration, command string, args []string, read func(*bufio.Reader) error) error {
+	conn, err := ndbMgmDial(ctx, address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Abort blocking reads when the scrape gets cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := ndbMgmSend(conn, command, args); err != nil {
+		return err
+	}
+	return read(bufio.NewReader(conn))
+}
+
+func ndbMgmDial(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// ndbMgmSend writes a command, its arguments and the terminating empty line.
+func ndbMgmSend(conn net.Conn, command string, args []string) error {
+	var b strings.Builder
+	b.WriteString(command + "\n")
+	for _, arg := range args {
+		b.WriteString(arg + "\n")
+	}
+	b.WriteString("\n")
+	_, err := conn.Write([]byte(b.String()))
+	return err
+}
+
+// ndbMgmReadReply reads a reply header and its "name: value" lines up to the
+// empty line that ends them.
+func ndbMgmReadReply(r *bufio.Reader, header string) (map[string]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(line) != header {
+		return nil, fmt.Errorf("unexpected reply from management server: %q", strings.TrimSpace(line))
+	}
+	values := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return values, err
+		}
+		key, value, ok := ndbMgmSplitLine(line)
+		if !ok {
+			return nil, fmt.Errorf("malformed line from management server: %q", line)
+		}
+		values[key] = value
+	}
+}
+
+// ndbMgmAddresses returns the management servers of collect.ndb_mgm.connectstring.
+func ndbMgmAddresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(*ndbMgmConnectString, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// parseNdbMgmStatus parses the reply to `get status`, which looks like:
+//
+//	node status
+//	nodes: 2
+//	node.1.type: NDB
+//	node.1.status: STARTED
+//	...
+//
+// and is terminated by an empty line.
+func parseNdbMgmStatus(r *bufio.Reader) ([]ndbMgmNode, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(header) != ndbMgmStatusReply {
+		return nil, fmt.Errorf("unexpected reply from management server: %q", strings.TrimSpace(header))
+	}
+
+	nodes := map[uint64]*ndbMgmNode{}
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil && len(nodes) == 0 {
+				return nil, err
+			}
+			break
+		}
+		key, value, ok := ndbMgmSplitLine(line)
+		if !ok {
+			return nil, fmt.Errorf("malformed line from management server: %q", line)
+		}
+		if key == "nodes" {
+			continue
+		}
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 || parts[0] != "node" {
+			continue
+		}
+		id, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed node id in %q", line)
+		}
+		node, ok := nodes[id]
+		if !ok {
+			node = &ndbMgmNode{id: id}
+			nodes[id] = node
+		}
+		switch parts[2] {
+		case "type":
+			node.nodeType = value
+		case "status":
+			node.status = value
+		case "version":
+			node.version, _ = strconv.ParseUint(value, 10, 64)
+		case "mysql_version":
+			node.mysqlVersion, _ = strconv.ParseUint(value, 10, 64)
+		case "startphase":
+			node.startPhase, _ = strconv.ParseUint(value, 10, 64)
+		case "node_group":
+			node.nodeGroup, _ = strconv.ParseUint(value, 10, 64)
+		case "connect_count":
+			node.connectCount, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+
+	result := make([]ndbMgmNode, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, *node)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result, nil
+}
+
+func ndbMgmSplitLine(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// ndbMgmFormatVersion converts a packed NDB version number to "major.minor.build".
+func ndbMgmFormatVersion(v uint64) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", (v>>16)&0xFF, (v>>8)&0xFF, v&0xFF)
+}
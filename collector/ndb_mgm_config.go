@@ -0,0 +1,322 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape the cluster configuration from the NDB management server (ndb_mgmd).
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	ndbMgmConfigCommand = "get config"
+	ndbMgmConfigReply   = "get config reply"
+	// The packed configuration is requested in the format of NDB 7.6, which
+	// all management servers since 7.x provide.
+	ndbMgmConfigVersion = 7<<16 | 6<<8
+)
+
+// Packed configuration format, see ConfigValues::pack of NDB.
+const (
+	ndbMgmConfigMagic = "NDBCONFV"
+
+	ndbMgmConfigIntType     = 1
+	ndbMgmConfigStringType  = 2
+	ndbMgmConfigSectionType = 3
+	ndbMgmConfigInt64Type   = 4
+
+	ndbMgmConfigTypeShift    = 28
+	ndbMgmConfigSectionShift = 14
+	ndbMgmConfigSectionMask  = 0x3FFF
+	ndbMgmConfigKeyMask      = 0x3FFF
+)
+
+// Configuration parameter ids, see ConfigParamId.h and mgmapi_config_parameters.h of NDB.
+const (
+	ndbMgmCfgSysConfigGeneration = 2
+	ndbMgmCfgNodeID              = 3
+	ndbMgmCfgNodeHost            = 5
+	ndbMgmCfgTypeOfSection       = 999
+	ndbMgmCfgSectionSystem       = 1000
+	ndbMgmCfgSectionNode         = 2000
+)
+
+// ndbMgmNodeTypes maps the node types of the configuration to those of `get status`.
+var ndbMgmNodeTypes = map[uint32]string{
+	0: "NDB",
+	1: "API",
+	2: "MGM",
+}
+
+// Metric descriptors.
+var (
+	ndbMgmConfigGenerationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "config_generation"),
+		"Generation of the configuration of the management server, incremented by each change",
+		nil, nil,
+	)
+	ndbMgmConfigNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "config_nodes"),
+		"Number of nodes of each type in the configuration",
+		[]string{"nodeType"}, nil,
+	)
+	ndbMgmConfigNodeInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "config_node_info"),
+		"Node in the configuration, compare with ndb_mgm_node_status to find nodes that never connected",
+		[]string{"nodeID", "nodeType", "hostName"}, nil,
+	)
+)
+
+// ndbMgmSection references another section of the packed configuration.
+type ndbMgmSection uint32
+
+// ndbMgmConfig is an unpacked configuration: the values by key of each section.
+// Values are uint32, uint64, string or ndbMgmSection.
+type ndbMgmConfig map[uint32]map[uint32]interface{}
+
+// ScrapeNdbMgmConfig collects the cluster configuration from the NDB management server.
+type ScrapeNdbMgmConfig struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbMgmConfig) Name() string {
+	return "ndb_mgm.config"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeNdbMgmConfig) Help() string {
+	return "Collect the nodes of the cluster configuration from the NDB management server using the management protocol"
+}
+
+// Version of MySQL from which scraper is available.
+func (ScrapeNdbMgmConfig) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
+}
+
+// Standalone reports that the scraper does not use the MySQL connection.
+func (ScrapeNdbMgmConfig) Standalone() bool {
+	return true
+}
+
+// Scrape collects data from the management server and sends it over channel as prometheus metric.
+// The database connection is not used.
+func (ScrapeNdbMgmConfig) Scrape(ctx context.Context, _ *sql.DB, ch chan<- prometheus.Metric) error {
+	var lastErr error
+	for _, address := range ndbMgmAddresses() {
+		config, err := ndbMgmGetConfig(ctx, address)
+		if err != nil {
+			log.Debugf("Error reading the configuration of NDB management server %s: %s", address, err)
+			lastErr = err
+			continue
+		}
+		ndbMgmExportConfig(config, ch)
+		return nil
+	}
+	if lastErr == nil {
+		return fmt.Errorf("no NDB management server address configured")
+	}
+	return lastErr
+}
+
+func ndbMgmExportConfig(config ndbMgmConfig, ch chan<- prometheus.Metric) {
+	for _, system := range config.sections(ndbMgmCfgSectionSystem) {
+		if generation, ok := system[ndbMgmCfgSysConfigGeneration].(uint32); ok {
+			ch <- prometheus.MustNewConstMetric(ndbMgmConfigGenerationDesc, prometheus.GaugeValue, float64(generation))
+		}
+	}
+
+	nodes := map[string]float64{}
+	for _, nodeType := range ndbMgmNodeTypes {
+		nodes[nodeType] = 0
+	}
+	for _, node := range config.sections(ndbMgmCfgSectionNode) {
+		id, _ := node[ndbMgmCfgNodeID].(uint32)
+		typeOfSection, _ := node[ndbMgmCfgTypeOfSection].(uint32)
+		nodeType, ok := ndbMgmNodeTypes[typeOfSection]
+		if !ok {
+			nodeType = strconv.FormatUint(uint64(typeOfSection), 10)
+		}
+		host, _ := node[ndbMgmCfgNodeHost].(string)
+		nodes[nodeType]++
+		ch <- prometheus.MustNewConstMetric(
+			ndbMgmConfigNodeInfoDesc, prometheus.GaugeValue, 1,
+			strconv.FormatUint(uint64(id), 10), nodeType, host)
+	}
+
+	nodeTypes := make([]string, 0, len(nodes))
+	for nodeType := range nodes {
+		nodeTypes = append(nodeTypes, nodeType)
+	}
+	sort.Strings(nodeTypes)
+	for _, nodeType := range nodeTypes {
+		ch <- prometheus.MustNewConstMetric(ndbMgmConfigNodesDesc, prometheus.GaugeValue, nodes[nodeType], nodeType)
+	}
+}
+
+// ndbMgmGetConfig connects to the management server at address and issues `get config`.
+func ndbMgmGetConfig(ctx context.Context, address string) (ndbMgmConfig, error) {
+	var config ndbMgmConfig
+	args := []string{fmt.Sprintf("version: %d", ndbMgmConfigVersion)}
+	err := ndbMgmCall(ctx, address, *ndbMgmTimeout, ndbMgmConfigCommand, args, func(r *bufio.Reader) (err error) {
+		config, err = readNdbMgmConfig(r)
+		return err
+	})
+	return config, err
+}
+
+// readNdbMgmConfig reads the reply to `get config`, which looks like:
+//
+//	get config reply
+//	result: Ok
+//	Content-Length: 4400
+//	Content-Type: ndbconfig/octet-stream
+//	Content-Transfer-Encoding: base64
+//
+//	TkRCQ09ORlYAAAA...
+//
+// where the content is the base64 encoded packed configuration.
+func readNdbMgmConfig(r *bufio.Reader) (ndbMgmConfig, error) {
+	reply, err := ndbMgmReadReply(r, ndbMgmConfigReply)
+	if err != nil {
+		return nil, err
+	}
+	if reply["result"] != "Ok" {
+		return nil, fmt.Errorf("management server refused the configuration: %s", reply["result"])
+	}
+	if encoding := reply["Content-Transfer-Encoding"]; encoding != "base64" {
+		return nil, fmt.Errorf("unexpected configuration encoding %q", encoding)
+	}
+	length, err := strconv.Atoi(reply["Content-Length"])
+	if err != nil {
+		return nil, fmt.Errorf("malformed configuration length %q", reply["Content-Length"])
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	packed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(content)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("malformed configuration: %s", err)
+	}
+	return unpackNdbMgmConfig(packed)
+}
+
+// unpackNdbMgmConfig decodes a packed configuration: the magic, the keys with
+// their values as big endian 32 bit words, and a checksum xor'ing all words.
+// A key holds the type of its value, its section and the parameter id.
+func unpackNdbMgmConfig(packed []byte) (ndbMgmConfig, error) {
+	if !bytes.HasPrefix(packed, []byte(ndbMgmConfigMagic)) || len(packed)%4 != 0 || len(packed) < len(ndbMgmConfigMagic)+4 {
+		return nil, fmt.Errorf("malformed configuration")
+	}
+	words := make([]uint32, len(packed)/4)
+	var checksum uint32
+	for i := range words {
+		words[i] = binary.BigEndian.Uint32(packed[4*i:])
+		if i < len(words)-1 {
+			checksum ^= words[i]
+		}
+	}
+	if checksum != words[len(words)-1] {
+		return nil, fmt.Errorf("configuration checksum mismatch")
+	}
+
+	config := ndbMgmConfig{}
+	words = words[len(ndbMgmConfigMagic)/4 : len(words)-1]
+	next := func() (uint32, error) {
+		if len(words) == 0 {
+			return 0, fmt.Errorf("truncated configuration")
+		}
+		word := words[0]
+		words = words[1:]
+		return word, nil
+	}
+	for len(words) > 0 {
+		key, _ := next()
+		section := key >> ndbMgmConfigSectionShift & ndbMgmConfigSectionMask
+		var value interface{}
+		switch key >> ndbMgmConfigTypeShift {
+		case ndbMgmConfigIntType, ndbMgmConfigSectionType:
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+			if key>>ndbMgmConfigTypeShift == ndbMgmConfigSectionType {
+				value = ndbMgmSection(v >> ndbMgmConfigSectionShift & ndbMgmConfigSectionMask)
+			}
+		case ndbMgmConfigInt64Type:
+			hi, err := next()
+			if err != nil {
+				return nil, err
+			}
+			lo, err := next()
+			if err != nil {
+				return nil, err
+			}
+			value = uint64(hi)<<32 | uint64(lo)
+		case ndbMgmConfigStringType:
+			length, err := next()
+			if err != nil {
+				return nil, err
+			}
+			// The length includes the terminating NUL, the string is padded to whole words.
+			if length == 0 || uint64(length) > 4*uint64(len(words)) {
+				return nil, fmt.Errorf("malformed string length %d in configuration key %#x", length, key)
+			}
+			n := (int(length) + 3) / 4
+			start := len(packed) - 4*(len(words)+1)
+			if start+4*n > len(packed) {
+				return nil, fmt.Errorf("truncated configuration")
+			}
+			value = string(packed[start : start+int(length)-1])
+			words = words[n:]
+		default:
+			return nil, fmt.Errorf("unknown type in configuration key %#x", key)
+		}
+		if config[section] == nil {
+			config[section] = map[uint32]interface{}{}
+		}
+		config[section][key&ndbMgmConfigKeyMask] = value
+	}
+	return config, nil
+}
+
+// sections returns the sections of a kind, e.g. all nodes. The root section
+// references a list section, whose keys 0, 1, ... reference the sections.
+func (c ndbMgmConfig) sections(kind uint32) []map[uint32]interface{} {
+	list, ok := c[0][kind].(ndbMgmSection)
+	if !ok {
+		return nil
+	}
+	var sections []map[uint32]interface{}
+	for i := uint32(0); ; i++ {
+		section, ok := c[uint32(list)][i].(ndbMgmSection)
+		if !ok {
+			return sections
+		}
+		sections = append(sections, c[uint32(section)])
+	}
+}
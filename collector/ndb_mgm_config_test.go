@@ -0,0 +1,182 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// packNdbMgmConfig packs key value pairs like ConfigValues::pack of NDB.
+func packNdbMgmConfig(entries ...interface{}) []byte {
+	packed := []byte(ndbMgmConfigMagic)
+	word := func(w uint32) {
+		packed = append(packed, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(packed[len(packed)-4:], w)
+	}
+	for i := 0; i < len(entries); i += 3 {
+		section, key := entries[i].(int), entries[i+1].(int)
+		prefix := uint32(section<<ndbMgmConfigSectionShift | key)
+		switch value := entries[i+2].(type) {
+		case int:
+			word(ndbMgmConfigIntType<<ndbMgmConfigTypeShift | prefix)
+			word(uint32(value))
+		case uint64:
+			word(ndbMgmConfigInt64Type<<ndbMgmConfigTypeShift | prefix)
+			word(uint32(value >> 32))
+			word(uint32(value))
+		case string:
+			word(ndbMgmConfigStringType<<ndbMgmConfigTypeShift | prefix)
+			word(uint32(len(value) + 1))
+			packed = append(packed, value...)
+			packed = append(packed, make([]byte, 4-len(value)%4)...)
+		case ndbMgmSection:
+			word(ndbMgmConfigSectionType<<ndbMgmConfigTypeShift | prefix)
+			word(uint32(value) << ndbMgmConfigSectionShift)
+		}
+	}
+	var checksum uint32
+	for i := 0; i < len(packed); i += 4 {
+		checksum ^= binary.BigEndian.Uint32(packed[i:])
+	}
+	word(checksum)
+	return packed
+}
+
+// A cluster with two data nodes, a management node and an API node without host.
+var ndbMgmConfigFixture = packNdbMgmConfig(
+	0, ndbMgmCfgSectionSystem, ndbMgmSection(1),
+	0, ndbMgmCfgSectionNode, ndbMgmSection(3),
+	1, 0, ndbMgmSection(2),
+	2, ndbMgmCfgSysConfigGeneration, 7,
+	2, 3, "cluster1",
+	3, 0, ndbMgmSection(4),
+	3, 1, ndbMgmSection(5),
+	3, 2, ndbMgmSection(6),
+	3, 3, ndbMgmSection(7),
+	4, ndbMgmCfgNodeID, 1,
+	4, ndbMgmCfgTypeOfSection, 0,
+	4, ndbMgmCfgNodeHost, "10.0.0.1",
+	4, 113, uint64(1<<32),
+	5, ndbMgmCfgNodeID, 2,
+	5, ndbMgmCfgTypeOfSection, 0,
+	5, ndbMgmCfgNodeHost, "10.0.0.2",
+	6, ndbMgmCfgNodeID, 49,
+	6, ndbMgmCfgTypeOfSection, 2,
+	6, ndbMgmCfgNodeHost, "10.0.0.3",
+	7, ndbMgmCfgNodeID, 51,
+	7, ndbMgmCfgTypeOfSection, 1,
+	7, ndbMgmCfgNodeHost, "",
+)
+
+func TestScrapeNdbMgmConfig(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(ndbMgmConfigFixture)
+	reply := fmt.Sprintf("get config reply\nresult: Ok\nContent-Length: %d\nContent-Type: ndbconfig/octet-stream\nContent-Transfer-Encoding: base64\n\n%s\n\n", len(encoded), encoded)
+	address, stop := fakeNdbMgmServer(t, ndbMgmConfigCommand, reply)
+	defer stop()
+
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err := (ScrapeNdbMgmConfig{}).Scrape(context.Background(), nil, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	metricExpected := []MetricResult{
+		{labels: labelMap{}, value: 7, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "1", "nodeType": "NDB", "hostName": "10.0.0.1"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "2", "nodeType": "NDB", "hostName": "10.0.0.2"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "49", "nodeType": "MGM", "hostName": "10.0.0.3"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "51", "nodeType": "API", "hostName": ""}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeType": "API"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeType": "MGM"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeType": "NDB"}, value: 2, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range metricExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func TestUnpackNdbMgmConfig(t *testing.T) {
+	convey.Convey("Values of all types", t, func() {
+		config, err := unpackNdbMgmConfig(ndbMgmConfigFixture)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(config[2][3], convey.ShouldEqual, "cluster1")
+		convey.So(config[4][113], convey.ShouldEqual, uint64(1<<32))
+		convey.So(config[7][ndbMgmCfgNodeHost], convey.ShouldEqual, "")
+		convey.So(config.sections(ndbMgmCfgSectionNode), convey.ShouldHaveLength, 4)
+	})
+
+	convey.Convey("Corrupt configurations", t, func() {
+		corrupt := append([]byte{}, ndbMgmConfigFixture...)
+		corrupt[20]++
+		_, err := unpackNdbMgmConfig(corrupt)
+		convey.So(err, convey.ShouldBeError, "configuration checksum mismatch")
+
+		_, err = unpackNdbMgmConfig([]byte("NDBCONF2"))
+		convey.So(err, convey.ShouldBeError, "malformed configuration")
+
+		truncated := packNdbMgmConfig(0, ndbMgmCfgNodeHost, "a long host name")
+		truncated = append(truncated[:len(truncated)-12], truncated[len(truncated)-4:]...)
+		_, err = unpackNdbMgmConfig(truncated)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("String lengths are checked against the configuration with a valid checksum", t, func() {
+		key := uint32(ndbMgmConfigStringType<<ndbMgmConfigTypeShift | ndbMgmCfgNodeHost)
+		for _, length := range []uint32{4, 0, 5, 9, 0xFFFFFFFD, 0xFFFFFFFF} {
+			packed := []byte(ndbMgmConfigMagic)
+			for _, w := range []uint32{key, length, 0x61626300} {
+				packed = append(packed, 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(packed[len(packed)-4:], w)
+			}
+			var checksum uint32
+			for i := 0; i < len(packed); i += 4 {
+				checksum ^= binary.BigEndian.Uint32(packed[i:])
+			}
+			packed = append(packed, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(packed[len(packed)-4:], checksum)
+
+			config, err := unpackNdbMgmConfig(packed)
+			if length == 4 {
+				// A NUL terminated string of up to 4 bytes fits the single word.
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(config[0][ndbMgmCfgNodeHost], convey.ShouldEqual, "abc")
+				continue
+			}
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,508 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Count the events of the cluster log streamed by the NDB management server (ndb_mgmd),
+// and follow the progress of backups and local checkpoints they report.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	ndbMgmEventsCommand = "listen event"
+	ndbMgmEventsReply   = "listen event"
+	ndbMgmEventHeader   = "log event reply"
+	ndbMgmEventPing     = "<PING>"
+)
+
+// Event types of ndb_logevent.h whose data is exported beyond their count.
+const (
+	ndbLeLocalCheckpointStarted   = 6
+	ndbLeLocalCheckpointCompleted = 7
+	ndbLeBackupStarted            = 54
+	ndbLeBackupCompleted          = 56
+	ndbLeBackupAborted            = 57
+	ndbLeBackupStatus             = 62
+)
+
+// Tunable flags.
+var (
+	ndbMgmEventsFilter = kingpin.Flag(
+		"collect.ndb_mgm.events.filter",
+		"Space separated <category>=<level> of the cluster log events to count, as for CLUSTERLOG in the ndb_mgm client.",
+	).Default("STARTUP=15 SHUTDOWN=15 NODERESTART=15 CONNECTION=15 ERROR=15 BACKUP=15 CHECKPOINT=7").String()
+	ndbMgmEventsIdleTimeout = kingpin.Flag(
+		"collect.ndb_mgm.events.idle_timeout",
+		"Reconnect when nothing, not even a ping, was received from the management server for this long.",
+	).Default("1m").Duration()
+)
+
+// Metric descriptors.
+var (
+	ndbMgmEventsUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_up"),
+		"Whether the exporter is listening to the events of a management server, 0 while reconnecting after the stream broke or stayed silent for collect.ndb_mgm.events.idle_timeout",
+		nil, nil,
+	)
+	ndbMgmEventsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_total"),
+		"Number of cluster log events received since the exporter started listening, by source node and NDB_LE_* event type of ndb_logevent.h",
+		[]string{"nodeID", "type"}, nil,
+	)
+	ndbMgmEventsBackupIDDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_id"),
+		"ID of the last backup reported by each data node since the exporter started listening",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsBackupRunningDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_running"),
+		"Whether the last backup reported by each data node is still running on it",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsBackupRecordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_records"),
+		"Number of records written by each data node for the last backup as of its last status report, see BackupReportFrequency",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsBackupBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_bytes"),
+		"Number of bytes written by each data node for the last backup as of its last status report",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsBackupLogRecordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_log_records"),
+		"Number of log records written by each data node for the last backup as of its last status report",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsBackupLogBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_backup_log_bytes"),
+		"Number of log bytes written by each data node for the last backup as of its last status report",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsLCPIDDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_lcp_completed_id"),
+		"ID of the last local checkpoint completed, by the data node reporting it",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsLCPTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_lcp_completed_timestamp_seconds"),
+		"Unix timestamp at which the exporter received the completion of the last local checkpoint, by the data node reporting it",
+		[]string{"nodeID"}, nil,
+	)
+	ndbMgmEventsLCPRunningDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbMgm, "events_lcp_running"),
+		"Whether a local checkpoint started and did not complete yet, by the data node reporting it",
+		[]string{"nodeID"}, nil,
+	)
+)
+
+// ndbMgmEventKey identifies the events counted together.
+type ndbMgmEventKey struct {
+	nodeID    uint64
+	eventType uint64
+}
+
+// ndbMgmBackup is the progress of the last backup reported by a data node.
+type ndbMgmBackup struct {
+	id, records, bytes, logRecords, logBytes uint64
+	running                                  bool
+}
+
+// ndbMgmLCP is the last local checkpoint reported by a data node.
+type ndbMgmLCP struct {
+	started, completed uint64
+	completedAt        time.Time
+}
+
+// ndbMgmEventState is a copy of what the listener received so far.
+type ndbMgmEventState struct {
+	listening bool
+	counts    map[ndbMgmEventKey]uint64
+	backups   map[uint64]ndbMgmBackup
+	lcps      map[uint64]ndbMgmLCP
+}
+
+// ndbMgmEventListener listens to the events of the first reachable management
+// server in the background, reconnecting when the connection is lost.
+type ndbMgmEventListener struct {
+	addresses   []string
+	filter      string
+	timeout     time.Duration
+	idleTimeout time.Duration
+	cancel      context.CancelFunc
+
+	mu        sync.Mutex
+	listening bool
+	counts    map[ndbMgmEventKey]uint64
+	backups   map[uint64]*ndbMgmBackup
+	lcps      map[uint64]*ndbMgmLCP
+}
+
+// The listener of the running config, events can't be listened to per scrape.
+var ndbMgmEvents struct {
+	sync.Mutex
+	listener *ndbMgmEventListener
+}
+
+// ScrapeNdbMgmEvents counts the cluster log events of the NDB management server.
+type ScrapeNdbMgmEvents struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbMgmEvents) Name() string {
+	return "ndb_mgm.events"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeNdbMgmEvents) Help() string {
+	return "Count the cluster log events streamed by the NDB management server and follow the backups and local checkpoints they report"
+}
+
+// Version of MySQL from which scraper is available.
+func (ScrapeNdbMgmEvents) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
+}
+
+// Standalone reports that the scraper does not use the MySQL connection.
+func (ScrapeNdbMgmEvents) Standalone() bool {
+	return true
+}
+
+// Scrape sends the events counted so far and the progress of the last backup
+// and local checkpoints over channel as prometheus metric.
+// It starts listening on the first scrape, and again when the flags changed.
+// The database connection is not used.
+func (ScrapeNdbMgmEvents) Scrape(ctx context.Context, _ *sql.DB, ch chan<- prometheus.Metric) error {
+	addresses := ndbMgmAddresses()
+	if len(addresses) == 0 {
+		return fmt.Errorf("no NDB management server address configured")
+	}
+	state := ndbMgmEventListenerFor(addresses, *ndbMgmEventsFilter).state()
+
+	var up float64
+	if state.listening {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(ndbMgmEventsUpDesc, prometheus.GaugeValue, up)
+
+	keys := make([]ndbMgmEventKey, 0, len(state.counts))
+	for key := range state.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].nodeID != keys[j].nodeID {
+			return keys[i].nodeID < keys[j].nodeID
+		}
+		return keys[i].eventType < keys[j].eventType
+	})
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(
+			ndbMgmEventsDesc, prometheus.CounterValue, float64(state.counts[key]),
+			strconv.FormatUint(key.nodeID, 10), strconv.FormatUint(key.eventType, 10))
+	}
+
+	nodeIDs := make([]uint64, 0, len(state.backups))
+	for nodeID := range state.backups {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+	for _, nodeID := range nodeIDs {
+		b := state.backups[nodeID]
+		node := strconv.FormatUint(nodeID, 10)
+		var running float64
+		if b.running {
+			running = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupIDDesc, prometheus.GaugeValue, float64(b.id), node)
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupRunningDesc, prometheus.GaugeValue, running, node)
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupRecordsDesc, prometheus.GaugeValue, float64(b.records), node)
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupBytesDesc, prometheus.GaugeValue, float64(b.bytes), node)
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupLogRecordsDesc, prometheus.GaugeValue, float64(b.logRecords), node)
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsBackupLogBytesDesc, prometheus.GaugeValue, float64(b.logBytes), node)
+	}
+
+	nodeIDs = nodeIDs[:0]
+	for nodeID := range state.lcps {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+	for _, nodeID := range nodeIDs {
+		lcp := state.lcps[nodeID]
+		node := strconv.FormatUint(nodeID, 10)
+		var running float64
+		if lcp.started > lcp.completed {
+			running = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ndbMgmEventsLCPRunningDesc, prometheus.GaugeValue, running, node)
+		if !lcp.completedAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(ndbMgmEventsLCPIDDesc, prometheus.GaugeValue, float64(lcp.completed), node)
+			ch <- prometheus.MustNewConstMetric(
+				ndbMgmEventsLCPTimestampDesc, prometheus.GaugeValue, float64(lcp.completedAt.UnixNano())/1e9, node)
+		}
+	}
+	return nil
+}
+
+// ndbMgmEventListenerFor returns the running listener, replacing it if it
+// listens to other management servers or events.
+func ndbMgmEventListenerFor(addresses []string, filter string) *ndbMgmEventListener {
+	ndbMgmEvents.Lock()
+	defer ndbMgmEvents.Unlock()
+
+	if l := ndbMgmEvents.listener; l != nil {
+		if strings.Join(l.addresses, ",") == strings.Join(addresses, ",") && l.filter == filter &&
+			l.idleTimeout == *ndbMgmEventsIdleTimeout {
+			return l
+		}
+		l.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &ndbMgmEventListener{
+		addresses:   addresses,
+		filter:      filter,
+		timeout:     *ndbMgmTimeout,
+		idleTimeout: *ndbMgmEventsIdleTimeout,
+		cancel:      cancel,
+		counts:      map[ndbMgmEventKey]uint64{},
+		backups:     map[uint64]*ndbMgmBackup{},
+		lcps:        map[uint64]*ndbMgmLCP{},
+	}
+	go l.run(ctx)
+	ndbMgmEvents.listener = l
+	return l
+}
+
+// stopNdbMgmEvents stops the running listener.
+func stopNdbMgmEvents() {
+	ndbMgmEvents.Lock()
+	defer ndbMgmEvents.Unlock()
+	if ndbMgmEvents.listener != nil {
+		ndbMgmEvents.listener.cancel()
+		ndbMgmEvents.listener = nil
+	}
+}
+
+// state returns a copy of what the listener received so far.
+func (l *ndbMgmEventListener) state() ndbMgmEventState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := ndbMgmEventState{
+		listening: l.listening,
+		counts:    make(map[ndbMgmEventKey]uint64, len(l.counts)),
+		backups:   make(map[uint64]ndbMgmBackup, len(l.backups)),
+		lcps:      make(map[uint64]ndbMgmLCP, len(l.lcps)),
+	}
+	for key, count := range l.counts {
+		state.counts[key] = count
+	}
+	for nodeID, backup := range l.backups {
+		state.backups[nodeID] = *backup
+	}
+	for nodeID, lcp := range l.lcps {
+		state.lcps[nodeID] = *lcp
+	}
+	return state
+}
+
+// run listens until ctx is done, trying the management servers in order and
+// backing off exponentially while none of them can be reached.
+func (l *ndbMgmEventListener) run(ctx context.Context) {
+	backoff := minReconnectBackoff
+	for {
+		for _, address := range l.addresses {
+			err := l.listen(ctx, address)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Debugf("Error listening to the events of NDB management server %s: %s", address, err)
+			if l.setListening(false) {
+				// The connection was lost, not refused: start backing off anew.
+				backoff = minReconnectBackoff
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// setListening sets whether the listener is connected and returns the previous value.
+func (l *ndbMgmEventListener) setListening(listening bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	was := l.listening
+	l.listening = listening
+	return was
+}
+
+// listen subscribes to the events of the management server at address and
+// counts them until the connection breaks or ctx is done.
+func (l *ndbMgmEventListener) listen(ctx context.Context, address string) error {
+	conn, err := ndbMgmDial(ctx, address, l.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Abort the blocking reads when listening is stopped.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(l.timeout)); err != nil {
+		return err
+	}
+	args := []string{"parsable: 1", "filter: " + l.filter}
+	if err := ndbMgmSend(conn, ndbMgmEventsCommand, args); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	reply, err := ndbMgmReadReply(r, ndbMgmEventsReply)
+	if err != nil {
+		return err
+	}
+	if reply["result"] != "0" {
+		return fmt.Errorf("management server refused to send events: %s", reply["msg"])
+	}
+	l.setListening(true)
+	return l.read(conn, r)
+}
+
+// read counts the events, which look like:
+//
+//	log event reply
+//	type=59
+//	time=7093541
+//	source_nodeid=2
+//	...
+//
+// and are terminated by an empty line. Pings keep the connection alive in
+// between, a connection silent for the idle timeout is given up: the
+// management server may be gone without closing it.
+func (l *ndbMgmEventListener) read(conn net.Conn, r *bufio.Reader) error {
+	readLine := func() (string, error) {
+		if err := conn.SetReadDeadline(time.Now().Add(l.idleTimeout)); err != nil {
+			return "", err
+		}
+		return r.ReadString('\n')
+	}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line == ndbMgmEventPing {
+			continue
+		}
+		if line != ndbMgmEventHeader {
+			return fmt.Errorf("unexpected event from management server: %q", line)
+		}
+		event := map[string]string{}
+		for {
+			line, err := readLine()
+			if err != nil {
+				return err
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if i := strings.Index(line, "="); i >= 0 {
+				event[line[:i]] = line[i+1:]
+			}
+		}
+		var key ndbMgmEventKey
+		if key.eventType, err = strconv.ParseUint(event["type"], 10, 32); err != nil {
+			return fmt.Errorf("malformed event type %q", event["type"])
+		}
+		if key.nodeID, err = strconv.ParseUint(event["source_nodeid"], 10, 32); err != nil {
+			return fmt.Errorf("malformed event source %q", event["source_nodeid"])
+		}
+		l.record(key, event, time.Now())
+	}
+}
+
+// record counts an event and follows the backups and local checkpoints of the
+// data node that sent it.
+func (l *ndbMgmEventListener) record(key ndbMgmEventKey, event map[string]string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.counts[key]++
+	switch key.eventType {
+	case ndbLeBackupStarted:
+		l.backups[key.nodeID] = &ndbMgmBackup{id: ndbMgmEventValue(event, "backup_id"), running: true}
+	case ndbLeBackupStatus, ndbLeBackupCompleted:
+		l.backups[key.nodeID] = &ndbMgmBackup{
+			id:         ndbMgmEventValue(event, "backup_id"),
+			records:    ndbMgmEventValue(event, "n_records"),
+			bytes:      ndbMgmEventValue(event, "n_bytes"),
+			logRecords: ndbMgmEventValue(event, "n_log_records"),
+			logBytes:   ndbMgmEventValue(event, "n_log_bytes"),
+			running:    key.eventType == ndbLeBackupStatus,
+		}
+	case ndbLeBackupAborted:
+		if b, ok := l.backups[key.nodeID]; ok && b.id == ndbMgmEventValue(event, "backup_id") {
+			b.running = false
+		}
+	case ndbLeLocalCheckpointStarted, ndbLeLocalCheckpointCompleted:
+		lcp, ok := l.lcps[key.nodeID]
+		if !ok {
+			lcp = &ndbMgmLCP{}
+			l.lcps[key.nodeID] = lcp
+		}
+		if key.eventType == ndbLeLocalCheckpointStarted {
+			lcp.started = ndbMgmEventValue(event, "lci")
+		} else {
+			lcp.completed, lcp.completedAt = ndbMgmEventValue(event, "lci"), now
+		}
+	}
+}
+
+// ndbMgmEventValue returns a number of an event, 0 if it is missing. 64 bit
+// numbers are split in two words, name or name_lo holding the low word and
+// name_hi the high one.
+func ndbMgmEventValue(event map[string]string, name string) uint64 {
+	lo, ok := event[name]
+	if !ok {
+		lo = event[name+"_lo"]
+	}
+	low, _ := strconv.ParseUint(lo, 10, 32)
+	high, _ := strconv.ParseUint(event[name+"_hi"], 10, 32)
+	return high<<32 | low
+}
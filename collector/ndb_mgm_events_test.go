@@ -0,0 +1,182 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const ndbMgmEventsFixture = `listen event
+result: 0
+
+log event reply
+type=0
+time=7093541
+source_nodeid=49
+node=1
+
+<PING>
+log event reply
+type=54
+time=7093542
+source_nodeid=1
+starting_node=49
+backup_id=3
+
+log event reply
+type=0
+time=7093543
+source_nodeid=49
+node=2
+
+`
+
+func TestScrapeNdbMgmEvents(t *testing.T) {
+	address, stop := fakeNdbMgmServer(t, ndbMgmEventsCommand, ndbMgmEventsFixture)
+	defer stop()
+	defer stopNdbMgmEvents()
+
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first scrape starts listening, the events arrive in the background.
+	var got []MetricResult
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if got, err = collectScraper(ScrapeNdbMgmEvents{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) == 9 && got[2].value == 2 {
+			break
+		}
+	}
+
+	convey.Convey("Events are counted per node and type, the backup started is followed", t, func() {
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			{labels: labelMap{}, value: 1, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1", "type": "54"}, value: 1, metricType: dto.MetricType_COUNTER},
+			{labels: labelMap{"nodeID": "49", "type": "0"}, value: 2, metricType: dto.MetricType_COUNTER},
+			{labels: labelMap{"nodeID": "1"}, value: 3, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1"}, value: 1, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+			{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+		})
+	})
+}
+
+func TestScrapeNdbMgmEventsRefused(t *testing.T) {
+	address, stop := fakeNdbMgmServer(t, ndbMgmEventsCommand, "listen event\nresult: -1\nmsg: Unknown category: >NOPE<\n\n")
+	defer stop()
+	defer stopNdbMgmEvents()
+
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+		"--collect.ndb_mgm.events.filter", "NOPE=15",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kingpin.CommandLine.Parse([]string{})
+
+	convey.Convey("Not listening", t, func() {
+		got, err := collectScraper(ScrapeNdbMgmEvents{}, nil)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldResemble, []MetricResult{{labels: labelMap{}, value: 0, metricType: dto.MetricType_GAUGE}})
+	})
+}
+
+func TestNdbMgmEventListenerRecord(t *testing.T) {
+	l := &ndbMgmEventListener{counts: map[ndbMgmEventKey]uint64{}, backups: map[uint64]*ndbMgmBackup{}, lcps: map[uint64]*ndbMgmLCP{}}
+	now := time.Unix(1600000000, 0)
+	record := func(eventType, nodeID uint64, event map[string]string) {
+		l.record(ndbMgmEventKey{nodeID: nodeID, eventType: eventType}, event, now)
+	}
+
+	convey.Convey("Backups and local checkpoints are followed per data node", t, func() {
+		record(ndbLeLocalCheckpointStarted, 1, map[string]string{"lci": "10", "keep_gci": "100", "restore_gci": "120"})
+		record(ndbLeLocalCheckpointCompleted, 1, map[string]string{"lci": "10"})
+		record(ndbLeLocalCheckpointStarted, 1, map[string]string{"lci": "11", "keep_gci": "110", "restore_gci": "130"})
+		record(ndbLeBackupStarted, 1, map[string]string{"starting_node": "50", "backup_id": "3"})
+		record(ndbLeBackupStarted, 2, map[string]string{"starting_node": "50", "backup_id": "3"})
+		record(ndbLeBackupStatus, 1, map[string]string{"starting_node": "50", "backup_id": "3",
+			"n_records_lo": "1000", "n_records_hi": "1", "n_log_records_lo": "10", "n_log_records_hi": "0",
+			"n_bytes_lo": "65536", "n_bytes_hi": "0", "n_log_bytes_lo": "512", "n_log_bytes_hi": "0"})
+
+		state := l.state()
+		convey.So(state.counts[ndbMgmEventKey{nodeID: 1, eventType: ndbLeLocalCheckpointStarted}], convey.ShouldEqual, 2)
+		convey.So(state.lcps, convey.ShouldResemble, map[uint64]ndbMgmLCP{1: {started: 11, completed: 10, completedAt: now}})
+		convey.So(state.backups, convey.ShouldResemble, map[uint64]ndbMgmBackup{
+			1: {id: 3, records: 1<<32 + 1000, bytes: 65536, logRecords: 10, logBytes: 512, running: true},
+			2: {id: 3, running: true},
+		})
+
+		record(ndbLeBackupCompleted, 1, map[string]string{"starting_node": "50", "backup_id": "3", "start_gci": "120", "stop_gci": "125",
+			"n_bytes": "131072", "n_records": "2000", "n_log_bytes": "1024", "n_log_records": "20"})
+		// The other node is still writing its part of the backup.
+		convey.So(l.state().backups, convey.ShouldResemble, map[uint64]ndbMgmBackup{
+			1: {id: 3, records: 2000, bytes: 131072, logRecords: 20, logBytes: 1024},
+			2: {id: 3, running: true},
+		})
+
+		record(ndbLeBackupStarted, 1, map[string]string{"starting_node": "50", "backup_id": "4"})
+		record(ndbLeBackupAborted, 1, map[string]string{"starting_node": "50", "backup_id": "4", "error": "1350"})
+		convey.So(l.state().backups[1], convey.ShouldResemble, ndbMgmBackup{id: 4})
+	})
+}
+
+func TestScrapeNdbMgmEventsIdle(t *testing.T) {
+	// The server sends a single event and then stays silent without closing the connection.
+	address, stop := fakeNdbMgmServer(t, ndbMgmEventsCommand, "listen event\nresult: 0\n\nlog event reply\ntype=0\nsource_nodeid=49\nnode=1\n\n")
+	defer stop()
+	defer stopNdbMgmEvents()
+
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+		"--collect.ndb_mgm.events.idle_timeout", "100ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kingpin.CommandLine.Parse([]string{})
+
+	// waitFor scrapes until the up gauge and the event count have the values.
+	waitFor := func(up, count float64) []MetricResult {
+		var got []MetricResult
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if got, err = collectScraper(ScrapeNdbMgmEvents{}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 2 && got[0].value == up && got[1].value == count {
+				break
+			}
+		}
+		return got
+	}
+
+	convey.Convey("A silent stream is given up and listened to again", t, func() {
+		convey.So(waitFor(1, 1)[0].value, convey.ShouldEqual, 1)
+		convey.So(waitFor(0, 1)[0].value, convey.ShouldEqual, 0)
+		convey.So(waitFor(1, 2)[1].value, convey.ShouldEqual, 2)
+	})
+}
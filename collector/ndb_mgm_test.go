@@ -0,0 +1,191 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const ndbMgmStatusFixture = `node status
+nodes: 4
+node.1.type: NDB
+node.1.status: STARTED
+node.1.version: 524311
+node.1.mysql_version: 524311
+node.1.startphase: 0
+node.1.dynamic_id: 1
+node.1.node_group: 0
+node.1.connect_count: 2
+node.1.address: 10.0.0.1
+node.2.type: NDB
+node.2.status: STARTING
+node.2.version: 524311
+node.2.mysql_version: 524311
+node.2.startphase: 4
+node.2.dynamic_id: 2
+node.2.node_group: 0
+node.2.connect_count: 1
+node.2.address: 10.0.0.2
+node.49.type: MGM
+node.49.status: CONNECTED
+node.49.version: 524311
+node.49.mysql_version: 524311
+node.49.startphase: 0
+node.49.dynamic_id: 0
+node.49.node_group: 0
+node.49.connect_count: 0
+node.49.address: 10.0.0.3
+node.51.type: API
+node.51.status: CONNECTED
+node.51.version: 524311
+node.51.mysql_version: 524311
+node.51.startphase: 0
+node.51.dynamic_id: 0
+node.51.node_group: 0
+node.51.connect_count: 0
+node.51.address: 10.0.0.4
+
+`
+
+// fakeNdbMgmServer answers command on a loopback port like ndb_mgmd does, and
+// keeps the connection open until the client closes it.
+func fakeNdbMgmServer(t *testing.T, command, reply string) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting fake management server: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				line, err := r.ReadString('\n')
+				if err != nil || strings.TrimSpace(line) != command {
+					return
+				}
+				// Skip arguments up to the terminating empty line.
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || strings.TrimSpace(line) == "" {
+						break
+					}
+				}
+				conn.Write([]byte(reply))
+				io.Copy(ioutil.Discard, r)
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestScrapeNdbMgmStatus(t *testing.T) {
+	address, stop := fakeNdbMgmServer(t, ndbMgmStatusCommand, ndbMgmStatusFixture)
+	defer stop()
+
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err := (ScrapeNdbMgmStatus{}).Scrape(context.Background(), nil, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	metricExpected := []MetricResult{
+		{labels: labelMap{"address": address}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "1", "nodeType": "NDB", "status": "STARTED"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "1", "nodeType": "NDB"}, value: 2, metricType: dto.MetricType_COUNTER},
+		{labels: labelMap{"nodeID": "1", "nodeType": "NDB", "version": "8.0.23", "mysqlVersion": "8.0.23"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "2", "nodeType": "NDB", "status": "STARTING"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "2", "nodeType": "NDB"}, value: 1, metricType: dto.MetricType_COUNTER},
+		{labels: labelMap{"nodeID": "2", "nodeType": "NDB", "version": "8.0.23", "mysqlVersion": "8.0.23"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "2"}, value: 4, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "2"}, value: 0, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "49", "nodeType": "MGM", "status": "CONNECTED"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "49", "nodeType": "MGM"}, value: 0, metricType: dto.MetricType_COUNTER},
+		{labels: labelMap{"nodeID": "49", "nodeType": "MGM", "version": "8.0.23", "mysqlVersion": "8.0.23"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "51", "nodeType": "API", "status": "CONNECTED"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{"nodeID": "51", "nodeType": "API"}, value: 0, metricType: dto.MetricType_COUNTER},
+		{labels: labelMap{"nodeID": "51", "nodeType": "API", "version": "8.0.23", "mysqlVersion": "8.0.23"}, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labelMap{}, value: 1, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range metricExpected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+	})
+}
+
+func TestScrapeNdbMgmStatusUnreachable(t *testing.T) {
+	// Grab a free port and close it again so nothing is listening there.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	_, err = kingpin.CommandLine.Parse([]string{
+		"--collect.ndb_mgm.connectstring", address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	var scrapeErr error
+	go func() {
+		scrapeErr = (ScrapeNdbMgmStatus{}).Scrape(context.Background(), nil, ch)
+		close(ch)
+	}()
+
+	convey.Convey("Management server down", t, func() {
+		got := readMetric(<-ch)
+		convey.So(got, convey.ShouldResemble, MetricResult{labels: labelMap{"address": address}, value: 0, metricType: dto.MetricType_GAUGE})
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+		convey.So(scrapeErr, convey.ShouldNotBeNil)
+	})
+}
+
+func TestParseNdbMgmStatusBadReply(t *testing.T) {
+	convey.Convey("Unexpected reply header", t, func() {
+		_, err := parseNdbMgmStatus(bufio.NewReader(strings.NewReader("result: Unknown command\n\n")))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
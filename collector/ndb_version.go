@@ -0,0 +1,148 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ndbinfoTablesQuery = `
+		SELECT TABLE_NAME
+		  FROM information_schema.tables
+		  WHERE TABLE_SCHEMA = 'ndbinfo'
+		`
+)
+
+var ndbVersionRE = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// Metric descriptors.
+var (
+	scraperUnsupportedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "collector_unsupported"),
+		"Whether the collector was skipped because the server lacks the NDB version or ndbinfo table it requires.",
+		[]string{"collector"}, nil,
+	)
+)
+
+// NdbScraper is implemented by scrapers which read ndbinfo tables that only exist
+// in some NDB Cluster versions.
+type NdbScraper interface {
+	Scraper
+
+	// NdbVersion of NDB Cluster from which scraper is available, e.g. "7.6.0".
+	NdbVersion() string
+
+	// NdbinfoTables the scraper reads from, e.g. "tc_time_track_stats". All of
+	// them must exist for the scraper to run.
+	NdbinfoTables() []string
+}
+
+// NdbVersion is a parsed NDB Cluster version.
+type NdbVersion struct {
+	Major, Minor, Patch int
+}
+
+// ParseNdbVersion parses the first "major.minor.patch" found in s, as in "ndb-7.6.12".
+func ParseNdbVersion(s string) (NdbVersion, error) {
+	m := ndbVersionRE.FindStringSubmatch(s)
+	if m == nil {
+		return NdbVersion{}, fmt.Errorf("no NDB version found in %q", s)
+	}
+	var v NdbVersion
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+// Less reports whether v is older than o.
+func (v NdbVersion) Less(o NdbVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+func (v NdbVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// ndbServer describes the NDB Cluster side of the connected SQL node.
+type ndbServer struct {
+	version NdbVersion
+	// tables in the ndbinfo schema, nil if they could not be read.
+	tables map[string]bool
+}
+
+// getNdbServer reads the available ndbinfo tables, returning nil if the server
+// is not an NDB Cluster SQL node. If the tables cannot be read the server is
+// returned without them together with the error.
+func getNdbServer(ctx context.Context, db *sql.DB, info ServerInfo) (*ndbServer, error) {
+	if info.NdbVersion == nil {
+		return nil, nil
+	}
+	server := &ndbServer{version: *info.NdbVersion}
+
+	rows, err := db.QueryContext(ctx, ndbinfoTablesQuery)
+	if err != nil {
+		return server, err
+	}
+	defer rows.Close()
+
+	var table string
+	tables := map[string]bool{}
+	for rows.Next() {
+		if err := rows.Scan(&table); err != nil {
+			return server, err
+		}
+		tables[table] = true
+	}
+	if err := rows.Err(); err != nil {
+		return server, err
+	}
+	server.tables = tables
+	return server, nil
+}
+
+// supports reports whether the scraper can run against this server. Without
+// the list of tables only the version is checked, and missing tables show up
+// as errors of the scraper.
+func (s *ndbServer) supports(scraper NdbScraper) bool {
+	if s == nil {
+		return false
+	}
+	required, err := ParseNdbVersion(scraper.NdbVersion())
+	if err != nil || s.version.Less(required) {
+		return false
+	}
+	if s.tables == nil {
+		return true
+	}
+	for _, table := range scraper.NdbinfoTables() {
+		if !s.tables[table] {
+			return false
+		}
+	}
+	return true
+}
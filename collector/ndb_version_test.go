@@ -0,0 +1,107 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestParseNdbVersion(t *testing.T) {
+	convey.Convey("NDB version parsing", t, func() {
+		v, err := ParseNdbVersion("ndb-7.6.12")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v, convey.ShouldResemble, NdbVersion{7, 6, 12})
+		convey.So(v.String(), convey.ShouldEqual, "7.6.12")
+
+		_, err = ParseNdbVersion("")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("NDB version ordering", t, func() {
+		convey.So(NdbVersion{7, 5, 7}.Less(NdbVersion{7, 5, 10}), convey.ShouldBeTrue)
+		convey.So(NdbVersion{7, 6, 0}.Less(NdbVersion{8, 0, 0}), convey.ShouldBeTrue)
+		convey.So(NdbVersion{8, 0, 23}.Less(NdbVersion{7, 6, 99}), convey.ShouldBeFalse)
+		convey.So(NdbVersion{7, 6, 0}.Less(NdbVersion{7, 6, 0}), convey.ShouldBeFalse)
+	})
+}
+
+func TestGetNdbServer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	convey.Convey("NDB 7.5 SQL node", t, func() {
+		mock.ExpectQuery(sanitizeQuery(ndbinfoTablesQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"TABLE_NAME"}).AddRow("memoryusage").AddRow("processes"))
+
+		info := ParseServerInfo("5.7.28-ndb-7.5.16-cluster-gpl", "MySQL Cluster Community Server (GPL)", "5.7.28", "ndb-7.5.16")
+		ndb, err := getNdbServer(context.Background(), db, info)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ndb, convey.ShouldNotBeNil)
+		convey.So(ndb.version, convey.ShouldResemble, NdbVersion{7, 5, 16})
+
+		convey.So(ndb.supports(ScrapeNdbinfoMemoryusage{}), convey.ShouldBeTrue)
+		convey.So(ndb.supports(ScrapeNdbinfoProcesses{}), convey.ShouldBeTrue)
+		// Introduced in 7.6.
+		convey.So(ndb.supports(ScrapeNdbinfoTcTimeTrack{}), convey.ShouldBeFalse)
+		// Table missing, e.g. due to missing privileges.
+		convey.So(ndb.supports(ScrapeNdbinfoLogspaces{}), convey.ShouldBeFalse)
+		// Reads ndbinfo.resources as well.
+		convey.So(ndb.supports(ScrapeNdbinfoFreeMemory{}), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Tables not readable", t, func() {
+		mock.ExpectQuery(sanitizeQuery(ndbinfoTablesQuery)).WillReturnError(fmt.Errorf("access denied"))
+
+		info := ParseServerInfo("5.7.28-ndb-7.5.16-cluster-gpl", "MySQL Cluster Community Server (GPL)", "5.7.28", "ndb-7.5.16")
+		ndb, err := getNdbServer(context.Background(), db, info)
+		convey.So(err, convey.ShouldBeError, "access denied")
+		// Only the version is checked, the scrapers report missing tables themselves.
+		convey.So(ndb.supports(ScrapeNdbinfoLogspaces{}), convey.ShouldBeTrue)
+		convey.So(ndb.supports(ScrapeNdbinfoTcTimeTrack{}), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Tables are cached by the pool", t, func() {
+		mock.ExpectQuery(sanitizeQuery(ndbinfoTablesQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"TABLE_NAME"}).AddRow("memoryusage").AddRow("resources"))
+
+		pool := NewPool(dsn)
+		info := ParseServerInfo("5.7.28-ndb-7.6.12-cluster-gpl", "MySQL Cluster Community Server (GPL)", "5.7.28", "ndb-7.6.12")
+		for i := 0; i < 2; i++ {
+			ndb, err := pool.NdbServer(context.Background(), db, info)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ndb.supports(ScrapeNdbinfoFreeMemory{}), convey.ShouldBeTrue)
+		}
+	})
+
+	convey.Convey("Plain MySQL server", t, func() {
+		info := ParseServerInfo("8.0.21", "MySQL Community Server - GPL", "8.0.21", "")
+		ndb, err := getNdbServer(context.Background(), db, info)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ndb, convey.ShouldBeNil)
+		convey.So(ndb.supports(ScrapeNdbinfoMemoryusage{}), convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
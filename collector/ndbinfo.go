@@ -13,5 +13,251 @@
 
 package collector
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
 // Subsystem.
 const ndbinfo = "ndbinfo"
+
+const (
+	ndbNodeIDQuery = `SELECT @@ndb_nodeid`
+	// Lowest node id of all SQL nodes connected to the cluster.
+	ndbinfoReporterQuery = `
+	SELECT MIN(node_id)
+	FROM ndbinfo.processes
+	WHERE process_name = 'mysqld';
+	`
+)
+
+// Tunable flags.
+var (
+	ndbinfoDesignatedReporter = kingpin.Flag(
+		"exporter.ndbinfo_designated_reporter",
+		"Only collect the cluster-wide ndbinfo metrics if this SQL node has the lowest node id of all connected SQL nodes.",
+	).Default("false").Bool()
+	ndbinfoTimeTrackByBlockInstance = kingpin.Flag(
+		"collect.ndbinfo.time_track_stats.by_block_instance",
+		"Break down the ndbinfo *_time_track_stats histograms by block instance in addition to node.",
+	).Default("false").Bool()
+	ndbinfoFragmentTables = kingpin.Flag(
+		"collect.ndbinfo.per_fragment.tables",
+		"Regexp of the fq_name (database/def/table) of the tables to collect ndbinfo *_per_fragment stats for, indexes follow their table.",
+	).Default(".*").String()
+	ndbinfoFragmentTablesExclude = kingpin.Flag(
+		"collect.ndbinfo.per_fragment.tables_exclude",
+		"Regexp of the fq_name (database/def/table) of the tables to skip in the ndbinfo *_per_fragment stats, indexes follow their table.",
+	).Default("").String()
+	ndbinfoFragmentByFragment = kingpin.Flag(
+		"collect.ndbinfo.per_fragment.by_fragment",
+		"Break down the ndbinfo *_per_fragment stats by node and fragment in addition to table.",
+	).Default("false").Bool()
+)
+
+var ndbinfoReporterDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, exporter, "ndbinfo_reporter"),
+	"Whether this instance is the designated reporter of the cluster-wide ndbinfo metrics.",
+	nil, nil,
+)
+
+// isNdbinfoScraper reports whether the scraper reads cluster-wide data from ndbinfo.
+func isNdbinfoScraper(scraper Scraper) bool {
+	return strings.HasPrefix(scraper.Name(), ndbinfo+".")
+}
+
+// isNdbinfoReporter reports whether the connected SQL node has the lowest node id
+// of all SQL nodes connected to the cluster and so should report ndbinfo metrics.
+func isNdbinfoReporter(ctx context.Context, db *sql.DB) (bool, error) {
+	var nodeID, reporterID sql.NullInt64
+	if err := db.QueryRowContext(ctx, ndbNodeIDQuery).Scan(&nodeID); err != nil {
+		return false, err
+	}
+	if err := db.QueryRowContext(ctx, ndbinfoReporterQuery).Scan(&reporterID); err != nil {
+		return false, err
+	}
+	// Not (yet) connected to the cluster, leave reporting to the others.
+	if !nodeID.Valid || nodeID.Int64 == 0 || !reporterID.Valid {
+		return false, nil
+	}
+	return nodeID.Int64 == reporterID.Int64, nil
+}
+
+// ndbinfoTimeTrackLabels returns the label names for the *_time_track_stats histograms.
+func ndbinfoTimeTrackLabels() []string {
+	if *ndbinfoTimeTrackByBlockInstance {
+		return []string{"nodeID", "blockInstance"}
+	}
+	return []string{"nodeID"}
+}
+
+// ndbinfoTimeTrack accumulates rows of the ndbinfo *_time_track_stats tables,
+// which report non-cumulative counts per upper_bound (in microseconds), into
+// cumulative Prometheus histograms with buckets in seconds.
+type ndbinfoTimeTrack struct {
+	keys   []string
+	series map[string]*ndbinfoTimeTrackSeries
+}
+
+type ndbinfoTimeTrackSeries struct {
+	labelValues []string
+	// counts holds one map of upper bound to count for every value column.
+	counts []map[uint64]uint64
+}
+
+func newNdbinfoTimeTrack() *ndbinfoTimeTrack {
+	return &ndbinfoTimeTrack{series: map[string]*ndbinfoTimeTrackSeries{}}
+}
+
+// add records the counts of one upper_bound row for the given label values.
+func (t *ndbinfoTimeTrack) add(labelValues []string, upperBound uint64, values ...uint64) {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := t.series[key]
+	if !ok {
+		s = &ndbinfoTimeTrackSeries{labelValues: labelValues, counts: make([]map[uint64]uint64, len(values))}
+		for i := range s.counts {
+			s.counts[i] = map[uint64]uint64{}
+		}
+		t.series[key] = s
+		t.keys = append(t.keys, key)
+	}
+	for i, v := range values {
+		s.counts[i][upperBound] += v
+	}
+}
+
+// collect sends one histogram per series and value column, descs must be in value column order.
+// The sum is estimated by assuming every observation lies in the middle of its bucket.
+func (t *ndbinfoTimeTrack) collect(ch chan<- prometheus.Metric, descs ...*prometheus.Desc) {
+	for _, key := range t.keys {
+		s := t.series[key]
+		for i, desc := range descs {
+			bounds := make([]uint64, 0, len(s.counts[i]))
+			for bound := range s.counts[i] {
+				bounds = append(bounds, bound)
+			}
+			sort.Slice(bounds, func(a, b int) bool { return bounds[a] < bounds[b] })
+
+			var (
+				count, lower uint64
+				sum          float64
+				buckets      = make(map[float64]uint64, len(bounds))
+			)
+			for _, bound := range bounds {
+				n := s.counts[i][bound]
+				count += n
+				sum += float64(n) * float64(lower+bound) / 2 / 1e6
+				buckets[float64(bound)/1e6] = count
+				lower = bound
+			}
+			ch <- prometheus.MustNewConstHistogram(desc, count, sum, buckets, s.labelValues...)
+		}
+	}
+}
+
+func newNdbinfoTimeTrackDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, name),
+		help, ndbinfoTimeTrackLabels(), nil,
+	)
+}
+
+// ndbinfoFragmentLabels returns the label names for the *_per_fragment metrics.
+func ndbinfoFragmentLabels() []string {
+	if *ndbinfoFragmentByFragment {
+		return []string{"fqName", "parentFqName", "type", "nodeID", "fragmentNum"}
+	}
+	return []string{"fqName", "parentFqName", "type"}
+}
+
+func newNdbinfoFragmentDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, name),
+		help, ndbinfoFragmentLabels(), nil,
+	)
+}
+
+// ndbinfoFragmentFilter selects the tables whose fragments are collected.
+type ndbinfoFragmentFilter struct {
+	include, exclude *regexp.Regexp
+}
+
+func newNdbinfoFragmentFilter() (*ndbinfoFragmentFilter, error) {
+	include, err := regexp.Compile(*ndbinfoFragmentTables)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collect.ndbinfo.per_fragment.tables: %s", err)
+	}
+	f := &ndbinfoFragmentFilter{include: include}
+	if *ndbinfoFragmentTablesExclude != "" {
+		if f.exclude, err = regexp.Compile(*ndbinfoFragmentTablesExclude); err != nil {
+			return nil, fmt.Errorf("invalid collect.ndbinfo.per_fragment.tables_exclude: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// match reports whether a fragment is collected. Index fragments are matched by
+// their parent table, whose fq_name is in parent_fq_name.
+func (f *ndbinfoFragmentFilter) match(fqName, parentFqName string) bool {
+	name := fqName
+	if parentFqName != "" {
+		name = parentFqName
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return f.include.MatchString(name)
+}
+
+// ndbinfoFragmentStats sums the rows of the ndbinfo *_per_fragment tables per
+// table, or per fragment replica with collect.ndbinfo.per_fragment.by_fragment.
+type ndbinfoFragmentStats struct {
+	keys   []string
+	series map[string]*ndbinfoFragmentSeries
+}
+
+type ndbinfoFragmentSeries struct {
+	labelValues []string
+	values      []float64
+}
+
+func newNdbinfoFragmentStats() *ndbinfoFragmentStats {
+	return &ndbinfoFragmentStats{series: map[string]*ndbinfoFragmentSeries{}}
+}
+
+// add records the values of one fragment replica.
+func (t *ndbinfoFragmentStats) add(fqName, parentFqName, fragmentType string, nodeID, fragmentNum uint64, values ...float64) {
+	labelValues := []string{fqName, parentFqName, fragmentType}
+	if *ndbinfoFragmentByFragment {
+		labelValues = append(labelValues, strconv.FormatUint(nodeID, 10), strconv.FormatUint(fragmentNum, 10))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := t.series[key]
+	if !ok {
+		s = &ndbinfoFragmentSeries{labelValues: labelValues, values: make([]float64, len(values))}
+		t.series[key] = s
+		t.keys = append(t.keys, key)
+	}
+	for i, v := range values {
+		s.values[i] += v
+	}
+}
+
+// collect sends one metric per series and value column, descs and valueTypes
+// must be in value column order.
+func (t *ndbinfoFragmentStats) collect(ch chan<- prometheus.Metric, descs []*prometheus.Desc, valueTypes []prometheus.ValueType) {
+	for _, key := range t.keys {
+		s := t.series[key]
+		for i, desc := range descs {
+			ch <- prometheus.MustNewConstMetric(desc, valueTypes[i], s.values[i], s.labelValues...)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.backup_id`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ndbinfoBackupIDQuery = `
+	SELECT id
+	FROM ndbinfo.backup_id;
+	`
+
+var (
+	ndbinfoBackupIDDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "backup_id"),
+		"ID of the most recent backup of the cluster, 0 if none was taken",
+		nil, nil,
+	)
+)
+
+// ScrapeNdbinfoBackupID collects for `ndbinfo.backup_id`
+type ScrapeNdbinfoBackupID struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoBackupID) Name() string {
+	return "ndbinfo.backup_id"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoBackupID) Help() string {
+	return "Collect the ID of the most recent backup from ndbinfo.backup_id"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoBackupID) Version() MySQLVersion {
+	return MySQLVersion{8, 0}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoBackupID) NdbVersion() string {
+	return "8.0.24"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoBackupID) NdbinfoTables() []string {
+	return []string{"backup_id"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoBackupID) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	var id uint64
+	// The table is empty until the first backup.
+	err := db.QueryRowContext(ctx, ndbinfoBackupIDQuery).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		ndbinfoBackupIDDesc, prometheus.GaugeValue, float64(id))
+	return nil
+}
@@ -19,16 +19,47 @@ import (
 	"context"
 	"database/sql"
 	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+// The SUMs count the locks in each bucket of ndbinfoClusterLocksBuckets.
 const ndbinfoClusterLocksQuery = `
-	SELECT node_id, mode, state, op, count(*), avg(duration_millis) 
+	SELECT node_id, mode, state, op, count(*), avg(duration_millis), max(duration_millis),
+	sum(duration_millis), sum(duration_millis <= 1), sum(duration_millis <= 10),
+	sum(duration_millis <= 100), sum(duration_millis <= 1000), sum(duration_millis <= 10000),
+	sum(duration_millis <= 60000)
 	FROM ndbinfo.cluster_locks 
 	GROUP BY node_id, mode, state, op;
 	`
 
+// Lock ids are unique across all dictionary objects, so tableid also finds
+// the unique index or blob table a lock is taken on. A transaction waits for
+// one lock per row, the rows of a table are grouped into their longest wait.
+const ndbinfoClusterLocksWaitersQuery = `
+	SELECT l.node_id, l.transid, l.mode, l.op, IFNULL(o.fq_name, '') AS waiter_table,
+	MAX(l.duration_millis) AS waiter_duration
+	FROM ndbinfo.cluster_locks l
+	LEFT JOIN ndbinfo.dict_obj_info o ON l.tableid = o.id
+	WHERE l.state = 'W'
+	GROUP BY l.node_id, l.transid, l.mode, l.op, waiter_table
+	ORDER BY waiter_duration DESC
+	LIMIT ?;
+	`
+
+// Upper bounds of the lock duration histogram buckets in milliseconds.
+var ndbinfoClusterLocksBuckets = []float64{1, 10, 100, 1000, 10000, 60000}
+
+// Tunable flags.
+var (
+	ndbinfoClusterLocksTopWaiters = kingpin.Flag(
+		"collect.ndbinfo.cluster_locks.top_waiters",
+		"Number of longest waiting transactions to collect with the table they wait on from ndbinfo.cluster_locks, 0 to disable. Requires ndbinfo.dict_obj_info.",
+	).Default("0").Int()
+)
+
 var (
 	ndbinfoClusterLocksCountDesc = prometheus.NewDesc(
 		prometheus.BuildFQName("ndb", ndbinfo, "cluster_locks_count"),
@@ -40,6 +71,21 @@ var (
 		"Lock state average duraton for each node, mode, state and operation type",
 		[]string{"nodeID", "mode", "state", "operationType"}, nil,
 	)
+	ndbinfoClusterLocksMaxDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_locks_max_duration_seconds"),
+		"Lock state maximum duration for each node, mode, state and operation type",
+		[]string{"nodeID", "mode", "state", "operationType"}, nil,
+	)
+	ndbinfoClusterLocksDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_locks_duration_seconds"),
+		"Histogram of the time locks have been in their state for each node, mode, state and operation type",
+		[]string{"nodeID", "mode", "state", "operationType"}, nil,
+	)
+	ndbinfoClusterLocksWaiterDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_locks_waiter_duration_seconds"),
+		"Time the longest waiting transactions have been waiting for a lock, with their table",
+		[]string{"nodeID", "transID", "mode", "operationType", "fqName"}, nil,
+	)
 )
 
 // ScrapeNdbinfoClusterLocks collects for `ndbinfo.cluster_locks`
@@ -56,8 +102,21 @@ func (ScrapeNdbinfoClusterLocks) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoClusterLocks) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoClusterLocks) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoClusterLocks) NdbVersion() string {
+	return "7.5.3"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoClusterLocks) NdbinfoTables() []string {
+	if *ndbinfoClusterLocksTopWaiters > 0 {
+		return []string{"cluster_locks", "dict_obj_info"}
+	}
+	return []string{"cluster_locks"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -68,19 +127,20 @@ func (ScrapeNdbinfoClusterLocks) Scrape(ctx context.Context, db *sql.DB, ch chan
 	}
 	defer ndbinfoClusterLocksRows.Close()
 
-	//SELECT node_id, mode, state, op, count(*), avg(duration_millis)
-	//FROM cluster_locks
-	//GROUP BY node_id, mode, state, op;
 	var (
 		nodeID, count          uint64
-		average                float64
+		average, maximum, sum  float64
 		mode, state, operation string
+		bucketCounts           = make([]uint64, len(ndbinfoClusterLocksBuckets))
 	)
+	scanArgs := []interface{}{&nodeID, &mode, &state, &operation, &count, &average, &maximum, &sum}
+	for i := range bucketCounts {
+		scanArgs = append(scanArgs, &bucketCounts[i])
+	}
 
-	// Iterate over the memory settings
+	// Iterate over the lock groups
 	for ndbinfoClusterLocksRows.Next() {
-		if err := ndbinfoClusterLocksRows.Scan(
-			&nodeID, &mode, &state, &operation, &count, &average); err != nil {
+		if err := ndbinfoClusterLocksRows.Scan(scanArgs...); err != nil {
 			return err
 		}
 		ch <- prometheus.MustNewConstMetric(
@@ -90,6 +150,72 @@ func (ScrapeNdbinfoClusterLocks) Scrape(ctx context.Context, db *sql.DB, ch chan
 		ch <- prometheus.MustNewConstMetric(
 			ndbinfoClusterLocksAvgDurationDesc, prometheus.GaugeValue, average,
 			strconv.FormatUint(nodeID, 10), mode, state, operation)
+
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoClusterLocksMaxDurationDesc, prometheus.GaugeValue, maximum/1000,
+			strconv.FormatUint(nodeID, 10), mode, state, operation)
+
+		buckets := make(map[float64]uint64, len(ndbinfoClusterLocksBuckets))
+		for i, bound := range ndbinfoClusterLocksBuckets {
+			buckets[bound/1000] = bucketCounts[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(
+			ndbinfoClusterLocksDurationDesc, count, sum/1000, buckets,
+			strconv.FormatUint(nodeID, 10), mode, state, operation)
+	}
+	if err := ndbinfoClusterLocksRows.Err(); err != nil {
+		return err
+	}
+
+	if *ndbinfoClusterLocksTopWaiters > 0 {
+		return scrapeNdbinfoClusterLocksWaiters(ctx, db, ch)
+	}
+	return nil
+}
+
+// scrapeNdbinfoClusterLocksWaiters collects the longest waiting locks.
+func scrapeNdbinfoClusterLocksWaiters(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoClusterLocksWaitersRows, err := db.QueryContext(ctx, ndbinfoClusterLocksWaitersQuery, *ndbinfoClusterLocksTopWaiters)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoClusterLocksWaitersRows.Close()
+
+	var (
+		nodeID, transID         uint64
+		duration                float64
+		mode, operation, fqName string
+		keys                    []string
+		waiters                 = map[string][]string{}
+		durations               = map[string]float64{}
+	)
+
+	// Iterate over the waiting transactions
+	for ndbinfoClusterLocksWaitersRows.Next() {
+		if err := ndbinfoClusterLocksWaitersRows.Scan(
+			&nodeID, &transID, &mode, &operation, &fqName, &duration); err != nil {
+			return err
+		}
+		// The query groups the rows already, but a duplicate series would fail
+		// the whole scrape so rows with the same labels are merged regardless.
+		labelValues := []string{strconv.FormatUint(nodeID, 10), strconv.FormatUint(transID, 10), mode, operation, fqName}
+		key := strings.Join(labelValues, "\xff")
+		if _, ok := waiters[key]; !ok {
+			keys = append(keys, key)
+			waiters[key] = labelValues
+		}
+		if duration > durations[key] {
+			durations[key] = duration
+		}
+	}
+	if err := ndbinfoClusterLocksWaitersRows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoClusterLocksWaiterDurationDesc, prometheus.GaugeValue, durations[key]/1000,
+			waiters[key]...)
 	}
 	return nil
 }
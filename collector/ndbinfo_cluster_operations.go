@@ -51,8 +51,18 @@ func (ScrapeNdbinfoClusterOperations) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoClusterOperations) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoClusterOperations) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoClusterOperations) NdbVersion() string {
+	return "7.2.2"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoClusterOperations) NdbinfoTables() []string {
+	return []string{"cluster_operations"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -18,23 +18,120 @@ package collector
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+// One SUM per threshold is appended to the select list, and with
+// collect.ndbinfo.cluster_transactions.oldest the longest inactive transaction
+// of each group as "transid client_node_id client_block_ref mysql_connection_id".
+// The MySQL connection is only known for transactions of this SQL node.
 const ndbinfoClusterTransactionsQuery = `
-	SELECT node_id, state, count(*) as cnt
-	FROM ndbinfo.cluster_transactions
-	GROUP BY node_id, state
+	SELECT t.node_id, t.state, count(*) as cnt, MAX(t.inactive_seconds)%s%s
+	FROM ndbinfo.cluster_transactions t%s
+	GROUP BY t.node_id, t.state
 	`
 
+const (
+	ndbinfoClusterTransactionsOldestColumn = `,
+	SUBSTRING_INDEX(GROUP_CONCAT(
+		CONCAT_WS(' ', t.transid, t.client_node_id, t.client_block_ref, IFNULL(s.mysql_connection_id, 0))
+		ORDER BY t.inactive_seconds DESC), ',', 1)`
+	ndbinfoClusterTransactionsOldestJoin = `
+	LEFT JOIN ndbinfo.server_transactions s ON t.transid = s.transid`
+)
+
+// Tunable flags.
+var (
+	ndbinfoClusterTransactionsThresholds = inactiveThresholds{}
+	ndbinfoClusterTransactionsOldest     = kingpin.Flag(
+		"collect.ndbinfo.cluster_transactions.oldest",
+		"Collect the client node and MySQL connection of the longest inactive transaction of each node.",
+	).Default("false").Bool()
+)
+
+func init() {
+	kingpin.Flag(
+		"collect.ndbinfo.cluster_transactions.inactive_thresholds",
+		"Comma separated durations of whole seconds, count the transactions of each node inactive for at least this long.",
+	).Default("10s,60s").SetValue(&ndbinfoClusterTransactionsThresholds)
+}
+
+// inactiveThresholds holds the distinct thresholds in seconds in ascending order.
+type inactiveThresholds []int64
+
+func (t *inactiveThresholds) Set(value string) error {
+	seen := map[int64]bool{}
+	thresholds := inactiveThresholds{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		threshold, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		// ndbinfo reports whole seconds of inactivity.
+		if threshold < time.Second || threshold%time.Second != 0 {
+			return fmt.Errorf("threshold %s is not a whole number of seconds", v)
+		}
+		if seconds := int64(threshold / time.Second); !seen[seconds] {
+			seen[seconds] = true
+			thresholds = append(thresholds, seconds)
+		}
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+	*t = thresholds
+	return nil
+}
+
+func (t *inactiveThresholds) String() string {
+	s := make([]string, len(*t))
+	for i, seconds := range *t {
+		s[i] = (time.Duration(seconds) * time.Second).String()
+	}
+	return strings.Join(s, ",")
+}
+
+// ndbinfoClusterTransactionsSQL returns the query for the thresholds.
+func ndbinfoClusterTransactionsSQL(thresholds []int64, oldest bool) string {
+	var sums strings.Builder
+	for _, threshold := range thresholds {
+		fmt.Fprintf(&sums, ", SUM(t.inactive_seconds >= %d)", threshold)
+	}
+	if oldest {
+		return fmt.Sprintf(ndbinfoClusterTransactionsQuery, sums.String(), ndbinfoClusterTransactionsOldestColumn, ndbinfoClusterTransactionsOldestJoin)
+	}
+	return fmt.Sprintf(ndbinfoClusterTransactionsQuery, sums.String(), "", "")
+}
+
 var (
 	ndbinfoClusterTransactionsDesc = prometheus.NewDesc(
 		prometheus.BuildFQName("ndb", ndbinfo, "cluster_transactions"),
 		"Number of transactions for each node and state",
 		[]string{"nodeID", "state"}, nil,
 	)
+	ndbinfoClusterTransactionsMaxInactiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_transactions_max_inactive_seconds"),
+		"Time since the longest inactive transaction of each node was last active",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoClusterTransactionsInactiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_transactions_inactive"),
+		"Number of transactions of each node inactive for at least threshold seconds",
+		[]string{"nodeID", "threshold"}, nil,
+	)
+	ndbinfoClusterTransactionsOldestDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cluster_transactions_oldest_info"),
+		"Longest inactive transaction of each node with the API node and block reference of its client, and its MySQL connection id if run by this SQL node or 0, always 1",
+		[]string{"nodeID", "transID", "clientNodeID", "clientBlockRef", "mysqlConnectionID"}, nil,
+	)
 )
 
 // ScrapeNdbinfoClusterTransactions collects for `ndbinfo.cluster_transactions`
@@ -51,32 +148,104 @@ func (ScrapeNdbinfoClusterTransactions) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoClusterTransactions) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoClusterTransactions) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoClusterTransactions) NdbVersion() string {
+	return "7.2.2"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoClusterTransactions) NdbinfoTables() []string {
+	if *ndbinfoClusterTransactionsOldest {
+		return []string{"cluster_transactions", "server_transactions"}
+	}
+	return []string{"cluster_transactions"}
+}
+
+// ndbinfoClusterTransactionsNode aggregates the states of the transactions of a node.
+type ndbinfoClusterTransactionsNode struct {
+	nodeID      string
+	maxInactive uint64
+	inactive    []uint64
+	oldest      []string
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
 func (ScrapeNdbinfoClusterTransactions) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
-	ndbinfoClusterTransactionsRows, err := db.QueryContext(ctx, ndbinfoClusterTransactionsQuery)
+	thresholds := ndbinfoClusterTransactionsThresholds
+	oldest := *ndbinfoClusterTransactionsOldest
+	ndbinfoClusterTransactionsRows, err := db.QueryContext(ctx, ndbinfoClusterTransactionsSQL(thresholds, oldest))
 	if err != nil {
 		return err
 	}
 	defer ndbinfoClusterTransactionsRows.Close()
 
 	var (
-		nodeID, count uint64
-		state         string
+		nodeID, count, maxInactive uint64
+		state, oldestTransaction   string
+		counts                     = make([]uint64, len(thresholds))
+		nodes                      []*ndbinfoClusterTransactionsNode
+		byID                       = map[uint64]*ndbinfoClusterTransactionsNode{}
 	)
+	scanArgs := []interface{}{&nodeID, &state, &count, &maxInactive}
+	for i := range counts {
+		scanArgs = append(scanArgs, &counts[i])
+	}
+	if oldest {
+		scanArgs = append(scanArgs, &oldestTransaction)
+	}
 
-	// Iterate over the memory settings
+	// Iterate over the states of the transactions of each node
 	for ndbinfoClusterTransactionsRows.Next() {
-		if err := ndbinfoClusterTransactionsRows.Scan(
-			&nodeID, &state, &count); err != nil {
+		if err := ndbinfoClusterTransactionsRows.Scan(scanArgs...); err != nil {
 			return err
 		}
 		ch <- prometheus.MustNewConstMetric(
 			ndbinfoClusterTransactionsDesc, prometheus.GaugeValue, float64(count),
 			strconv.FormatUint(nodeID, 10), state)
+
+		node, ok := byID[nodeID]
+		if !ok {
+			node = &ndbinfoClusterTransactionsNode{nodeID: strconv.FormatUint(nodeID, 10), inactive: make([]uint64, len(thresholds))}
+			byID[nodeID] = node
+			nodes = append(nodes, node)
+		}
+		for i := range counts {
+			node.inactive[i] += counts[i]
+		}
+		// Keep the first of equally old transactions.
+		if !ok || maxInactive > node.maxInactive {
+			node.maxInactive = maxInactive
+			if oldest {
+				if node.oldest = strings.Fields(oldestTransaction); len(node.oldest) != 4 {
+					return fmt.Errorf("unexpected oldest transaction %q", oldestTransaction)
+				}
+			}
+		}
+	}
+	if err := ndbinfoClusterTransactionsRows.Err(); err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoClusterTransactionsMaxInactiveDesc, prometheus.GaugeValue, float64(node.maxInactive),
+			node.nodeID)
+		for i, threshold := range thresholds {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoClusterTransactionsInactiveDesc, prometheus.GaugeValue, float64(node.inactive[i]),
+				node.nodeID, strconv.FormatInt(threshold, 10))
+		}
+	}
+	if oldest {
+		for _, node := range nodes {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoClusterTransactionsOldestDesc, prometheus.GaugeValue, 1,
+				append([]string{node.nodeID}, node.oldest...)...)
+		}
 	}
 	return nil
 }
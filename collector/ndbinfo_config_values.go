@@ -0,0 +1,147 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.config_values` joined with `ndbinfo.config_params`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const ndbinfoConfigValuesQuery = `
+	SELECT v.node_id, p.param_name, p.param_type, v.config_value
+	FROM ndbinfo.config_values v
+	JOIN ndbinfo.config_params p ON v.config_param = p.param_number;
+	`
+
+// ndbinfoConfigNumericTypes lists the parameter types of ndbinfo.config_params
+// exported as gauges, bools as 0 or 1. All others are exported as info metrics.
+var ndbinfoConfigNumericTypes = map[string]bool{
+	"bool":     true,
+	"unsigned": true,
+	"signed":   true,
+}
+
+// Tunable flags.
+var (
+	ndbinfoConfigValuesParams = kingpin.Flag(
+		"collect.ndbinfo.config_values.params",
+		"Regexp of the names of the data node configuration parameters to collect from ndbinfo.config_values.",
+	).Default(".*").String()
+)
+
+var (
+	ndbinfoConfigValueDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "config_value"),
+		"Value of each numeric configuration parameter in use by each data node",
+		[]string{"nodeID", "paramName"}, nil,
+	)
+	ndbinfoConfigValueInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "config_value_info"),
+		"Value of each non-numeric configuration parameter in use by each data node, always 1",
+		[]string{"nodeID", "paramName", "value"}, nil,
+	)
+)
+
+// ScrapeNdbinfoConfigValues collects for `ndbinfo.config_values`
+type ScrapeNdbinfoConfigValues struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoConfigValues) Name() string {
+	return "ndbinfo.config_values"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoConfigValues) Help() string {
+	return "Collect the configuration parameters in use by each data node from ndbinfo.config_values"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoConfigValues) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoConfigValues) NdbVersion() string {
+	return "7.5.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoConfigValues) NdbinfoTables() []string {
+	return []string{"config_values", "config_params"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoConfigValues) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	params, err := regexp.Compile(*ndbinfoConfigValuesParams)
+	if err != nil {
+		return fmt.Errorf("invalid collect.ndbinfo.config_values.params: %s", err)
+	}
+
+	ndbinfoConfigValuesRows, err := db.QueryContext(ctx, ndbinfoConfigValuesQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoConfigValuesRows.Close()
+
+	var (
+		nodeID               uint64
+		paramName, paramType string
+		value                sql.NullString
+	)
+
+	// Iterate over the parameters of all data nodes
+	for ndbinfoConfigValuesRows.Next() {
+		if err := ndbinfoConfigValuesRows.Scan(&nodeID, &paramName, &paramType, &value); err != nil {
+			return err
+		}
+		if !value.Valid || !params.MatchString(paramName) {
+			continue
+		}
+		node := strconv.FormatUint(nodeID, 10)
+		if ndbinfoConfigNumericTypes[paramType] {
+			f, err := parseNdbinfoConfigValue(paramType, value.String)
+			if err != nil {
+				return fmt.Errorf("invalid value of %s parameter %s: %s", paramType, paramName, err)
+			}
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoConfigValueDesc, prometheus.GaugeValue, f,
+				node, paramName)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoConfigValueInfoDesc, prometheus.GaugeValue, 1,
+			node, paramName, value.String)
+	}
+	return ndbinfoConfigValuesRows.Err()
+}
+
+// parseNdbinfoConfigValue parses the value of a numeric parameter.
+func parseNdbinfoConfigValue(paramType, value string) (float64, error) {
+	if paramType != "bool" {
+		return strconv.ParseFloat(value, 64)
+	}
+	b, err := strconv.ParseBool(value)
+	if b {
+		return 1, err
+	}
+	return 0, err
+}
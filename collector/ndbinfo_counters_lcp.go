@@ -0,0 +1,92 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.counters.lcp`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ndbinfoCountersLCPQuery = `
+	SELECT node_id, counter_name, sum(val)
+	FROM ndbinfo.counters
+	WHERE counter_name LIKE 'LCP%'
+	GROUP BY node_id, counter_name;
+	`
+
+var (
+	ndbinfoCountersLCPDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "lcp_counter"),
+		"Local checkpoint event counters of each data node",
+		[]string{"nodeID", "counterName"}, nil,
+	)
+)
+
+// ScrapeNdbinfoCountersLCP collects for `ndbinfo.counters.lcp`
+type ScrapeNdbinfoCountersLCP struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoCountersLCP) Name() string {
+	return "ndbinfo.counters.lcp"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoCountersLCP) Help() string {
+	return "Collect the LCP_* counters from ndbinfo.counters"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoCountersLCP) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoCountersLCP) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoCountersLCP) NdbinfoTables() []string {
+	return []string{"counters"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoCountersLCP) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoCountersLCPRows, err := db.QueryContext(ctx, ndbinfoCountersLCPQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoCountersLCPRows.Close()
+
+	var (
+		nodeID, val uint64
+		counterName string
+	)
+
+	// Iterate over the counters of all data nodes
+	for ndbinfoCountersLCPRows.Next() {
+		if err := ndbinfoCountersLCPRows.Scan(&nodeID, &counterName, &val); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoCountersLCPDesc, prometheus.CounterValue, float64(val),
+			strconv.FormatUint(nodeID, 10), counterName)
+	}
+	return ndbinfoCountersLCPRows.Err()
+}
@@ -51,8 +51,18 @@ func (ScrapeNdbinfoCountersSPJ) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoCountersSPJ) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoCountersSPJ) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoCountersSPJ) NdbVersion() string {
+	return "7.2.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoCountersSPJ) NdbinfoTables() []string {
+	return []string{"counters"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
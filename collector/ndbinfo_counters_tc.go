@@ -51,8 +51,18 @@ func (ScrapeNdbinfoCountersTC) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoCountersTC) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoCountersTC) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoCountersTC) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoCountersTC) NdbinfoTables() []string {
+	return []string{"counters"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
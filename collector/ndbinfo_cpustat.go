@@ -0,0 +1,130 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.cpustat` and its `cpustat_50ms`, `cpustat_1sec` and `cpustat_20sec` variants
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// The history tables keep several measurements per thread, which are averaged.
+const ndbinfoCpustatQuery = `
+	SELECT c.node_id, c.thr_no, IFNULL(t.thread_name, ''),
+	AVG(c.OS_user), AVG(c.OS_system), AVG(c.OS_idle),
+	AVG(c.thread_exec), AVG(c.thread_sleeping), AVG(c.thread_spinning),
+	AVG(c.thread_send), AVG(c.thread_buffer_full)
+	FROM ndbinfo.%s c
+	LEFT JOIN ndbinfo.threads t ON c.node_id = t.node_id AND c.thr_no = t.thr_no
+	GROUP BY c.node_id, c.thr_no, t.thread_name;
+	`
+
+// Tunable flags.
+var (
+	ndbinfoCpustatTable = kingpin.Flag(
+		"collect.ndbinfo.cpustat.table",
+		"The ndbinfo cpustat table to collect from, history tables are averaged over their measurements.",
+	).Default("cpustat").Enum("cpustat", "cpustat_50ms", "cpustat_1sec", "cpustat_20sec")
+)
+
+var (
+	ndbinfoCpustatOSModes      = []string{"user", "system", "idle"}
+	ndbinfoCpustatThreadStates = []string{"exec", "sleeping", "spinning", "send", "buffer_full"}
+)
+
+var (
+	ndbinfoCpustatOSDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cpustat_os_percent"),
+		"Percentage of time the OS reports each thread on each node spent in user, system and idle mode",
+		[]string{"nodeID", "threadNO", "threadName", "mode"}, nil,
+	)
+	ndbinfoCpustatThreadDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "cpustat_thread_percent"),
+		"Percentage of time each thread on each node spent executing, sleeping, spinning, sending and blocked on full buffers as measured by the thread itself",
+		[]string{"nodeID", "threadNO", "threadName", "state"}, nil,
+	)
+)
+
+// ScrapeNdbinfoCpustat collects for `ndbinfo.cpustat`
+type ScrapeNdbinfoCpustat struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoCpustat) Name() string {
+	return "ndbinfo.cpustat"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoCpustat) Help() string {
+	return "Collect per thread CPU utilisation from ndbinfo.cpustat"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoCpustat) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoCpustat) NdbVersion() string {
+	return "7.5.2"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoCpustat) NdbinfoTables() []string {
+	return []string{*ndbinfoCpustatTable, "threads"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoCpustat) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoCpustatRows, err := db.QueryContext(ctx, fmt.Sprintf(ndbinfoCpustatQuery, *ndbinfoCpustatTable))
+	if err != nil {
+		return err
+	}
+	defer ndbinfoCpustatRows.Close()
+
+	var (
+		nodeID, threadNO                    uint64
+		threadName                          string
+		osUser, osSystem, osIdle            float64
+		exec, sleeping, spinning, send, buf float64
+	)
+
+	// Iterate over the threads of each node
+	for ndbinfoCpustatRows.Next() {
+		if err := ndbinfoCpustatRows.Scan(
+			&nodeID, &threadNO, &threadName,
+			&osUser, &osSystem, &osIdle,
+			&exec, &sleeping, &spinning, &send, &buf); err != nil {
+			return err
+		}
+		labels := []string{strconv.FormatUint(nodeID, 10), strconv.FormatUint(threadNO, 10), threadName}
+
+		for i, value := range []float64{osUser, osSystem, osIdle} {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoCpustatOSDesc, prometheus.GaugeValue, value,
+				append(labels, ndbinfoCpustatOSModes[i])...)
+		}
+		for i, value := range []float64{exec, sleeping, spinning, send, buf} {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoCpustatThreadDesc, prometheus.GaugeValue, value,
+				append(labels, ndbinfoCpustatThreadStates[i])...)
+		}
+	}
+	return ndbinfoCpustatRows.Err()
+}
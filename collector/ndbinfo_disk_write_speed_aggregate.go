@@ -66,8 +66,18 @@ func (ScrapeNdbinfoDiskWriteSpeedAggregate) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoDiskWriteSpeedAggregate) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoDiskWriteSpeedAggregate) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoDiskWriteSpeedAggregate) NdbVersion() string {
+	return "7.4.1"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoDiskWriteSpeedAggregate) NdbinfoTables() []string {
+	return []string{"disk_write_speed_aggregate"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -0,0 +1,126 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.disk_write_speed_base`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The table keeps one row per LDM thread and measurement period of about the
+// last minute, a period ended millis_ago and lasted millis_passed.
+const ndbinfoDiskWriteSpeedBaseQuery = `
+	SELECT node_id, SUM(backup_lcp_bytes_written), SUM(redo_bytes_written),
+	MAX(millis_ago + millis_passed),
+	MIN(IF(backup_lcp_bytes_written > 0, millis_ago, NULL))
+	FROM ndbinfo.disk_write_speed_base
+	GROUP BY node_id;
+	`
+
+var (
+	ndbinfoDiskWriteSpeedBaseLcpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "disk_write_lcp_bytes"),
+		"Number of bytes written to disk by backup and LCP processes of each node over the measurement window",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoDiskWriteSpeedBaseRedoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "disk_write_redo_bytes"),
+		"Number of bytes written to the REDO log of each node over the measurement window",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoDiskWriteSpeedBaseWindowDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "disk_write_window_seconds"),
+		"Length of the measurement window of ndbinfo.disk_write_speed_base for each node",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoDiskWriteSpeedBaseLcpIdleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "disk_write_lcp_idle_seconds"),
+		"Time since backup and LCP processes of each node last wrote to disk, equal to the measurement window if they did not write within it",
+		[]string{"nodeID"}, nil,
+	)
+)
+
+// ScrapeNdbinfoDiskWriteSpeedBase collects for `ndbinfo.disk_write_speed_base`
+type ScrapeNdbinfoDiskWriteSpeedBase struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoDiskWriteSpeedBase) Name() string {
+	return "ndbinfo.disk_write_speed_base"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoDiskWriteSpeedBase) Help() string {
+	return "Collect backup, LCP and REDO log writes of the last minute from ndbinfo.disk_write_speed_base"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoDiskWriteSpeedBase) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoDiskWriteSpeedBase) NdbVersion() string {
+	return "7.4.1"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoDiskWriteSpeedBase) NdbinfoTables() []string {
+	return []string{"disk_write_speed_base"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoDiskWriteSpeedBase) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoDiskWriteSpeedBaseRows, err := db.QueryContext(ctx, ndbinfoDiskWriteSpeedBaseQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoDiskWriteSpeedBaseRows.Close()
+
+	var (
+		nodeID, lcpBytes, redoBytes, windowMillis uint64
+		lcpIdleMillis                             sql.NullInt64
+	)
+
+	// Iterate over the data nodes
+	for ndbinfoDiskWriteSpeedBaseRows.Next() {
+		if err := ndbinfoDiskWriteSpeedBaseRows.Scan(
+			&nodeID, &lcpBytes, &redoBytes, &windowMillis, &lcpIdleMillis); err != nil {
+			return err
+		}
+		node := strconv.FormatUint(nodeID, 10)
+		lcpIdle := float64(windowMillis)
+		if lcpIdleMillis.Valid {
+			lcpIdle = float64(lcpIdleMillis.Int64)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoDiskWriteSpeedBaseLcpDesc, prometheus.GaugeValue, float64(lcpBytes),
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoDiskWriteSpeedBaseRedoDesc, prometheus.GaugeValue, float64(redoBytes),
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoDiskWriteSpeedBaseWindowDesc, prometheus.GaugeValue, float64(windowMillis)/1000,
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoDiskWriteSpeedBaseLcpIdleDesc, prometheus.GaugeValue, lcpIdle/1000,
+			node)
+	}
+	return ndbinfoDiskWriteSpeedBaseRows.Err()
+}
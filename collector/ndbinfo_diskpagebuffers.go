@@ -83,8 +83,18 @@ func (ScrapeNdbinfoDiskpagebuffers) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoDiskpagebuffers) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoDiskpagebuffers) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoDiskpagebuffers) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoDiskpagebuffers) NdbinfoTables() []string {
+	return []string{"diskpagebuffer"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
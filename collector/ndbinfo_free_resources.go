@@ -62,8 +62,18 @@ func (ScrapeNdbinfoFreeMemory) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoFreeMemory) Version() float64 {
-	return 5.7
+func (ScrapeNdbinfoFreeMemory) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoFreeMemory) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoFreeMemory) NdbinfoTables() []string {
+	return []string{"resources", "memoryusage"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -0,0 +1,126 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Estimate when the logs of `ndbinfo.logspaces` and `ndbinfo.logbuffers` fill up.
+
+package collector
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Tunable flags.
+var (
+	ndbinfoLogFillWindow = kingpin.Flag(
+		"collect.ndbinfo.log_fill.window",
+		"Time window of the samples used to estimate when the ndbinfo logspaces and logbuffers fill up.",
+	).Default("5m").Duration()
+)
+
+type ndbinfoLogFillSample struct {
+	time time.Time
+	used float64
+}
+
+// ndbinfoLogUsage is the usage of one log part read by a scrape, and the
+// estimate of when it fills up derived from it.
+type ndbinfoLogUsage struct {
+	labelValues []string
+	used, total uint64
+
+	secondsUntilFull float64
+	estimated        bool
+}
+
+// ndbinfoLogFill remembers the recent usage of the log parts of the cluster
+// behind a Pool. It lives as long as the Pool, so reconnects keep the samples.
+type ndbinfoLogFill struct {
+	mu      sync.Mutex
+	samples map[string][]ndbinfoLogFillSample
+}
+
+func newNdbinfoLogFill() *ndbinfoLogFill {
+	return &ndbinfoLogFill{samples: map[string][]ndbinfoLogFillSample{}}
+}
+
+// ndbinfoLogFillFromContext returns the samples of the Pool being scraped. A
+// scraper run without a Pool starts from scratch and never has an estimate.
+func ndbinfoLogFillFromContext(ctx context.Context) *ndbinfoLogFill {
+	if p := poolFromContext(ctx); p != nil {
+		return p.logFill
+	}
+	return newNdbinfoLogFill()
+}
+
+// update adds the usage of the log parts of table at now and fills in their
+// estimates. Log parts that were not seen within the window are dropped.
+func (f *ndbinfoLogFill) update(table string, now time.Time, usage []ndbinfoLogUsage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, samples := range f.samples {
+		if now.Sub(samples[len(samples)-1].time) > *ndbinfoLogFillWindow {
+			delete(f.samples, key)
+		}
+	}
+	for i := range usage {
+		u := &usage[i]
+		key := table + "\xff" + strings.Join(u.labelValues, "\xff")
+		u.secondsUntilFull, u.estimated = f.secondsUntilFull(key, now, u.used, u.total)
+	}
+}
+
+// secondsUntilFull records the usage of a log part and estimates the time until
+// it is full from the growth rate over collect.ndbinfo.log_fill.window. It is
+// +Inf if the usage does not grow, ok is false until there are two samples.
+// Must be called with mu held.
+func (f *ndbinfoLogFill) secondsUntilFull(key string, now time.Time, used, total uint64) (seconds float64, ok bool) {
+	samples := append(f.samples[key], ndbinfoLogFillSample{time: now, used: float64(used)})
+	for len(samples) > 0 && now.Sub(samples[0].time) > *ndbinfoLogFillWindow {
+		samples = samples[1:]
+	}
+	f.samples[key] = samples
+	if len(samples) < 2 {
+		return 0, false
+	}
+	if used >= total {
+		return 0, true
+	}
+
+	// Least squares slope of the usage over time, less sensitive to a single
+	// outlier than the difference between the first and last sample.
+	var sumT, sumU, sumTT, sumTU float64
+	n := float64(len(samples))
+	for _, s := range samples {
+		t := s.time.Sub(samples[0].time).Seconds()
+		sumT += t
+		sumU += s.used
+		sumTT += t * t
+		sumTU += t * s.used
+	}
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return 0, false
+	}
+	rate := (n*sumTU - sumT*sumU) / denominator
+	if rate <= 0 {
+		return math.Inf(1), true
+	}
+	return float64(total-used) / rate, true
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"database/sql"
 	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -40,6 +41,18 @@ var (
 		"Total space available for each log",
 		[]string{"nodeID", "logType", "logPart"}, nil,
 	)
+
+	ndbinfoLogspacesFillRatioDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "logspaces_fill_ratio"),
+		"Ratio of the space used by each log",
+		[]string{"nodeID", "logType", "logPart"}, nil,
+	)
+
+	ndbinfoLogspacesSecondsUntilFullDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "logspaces_seconds_until_full"),
+		"Estimated time until the space of each log is used up at its growth rate over collect.ndbinfo.log_fill.window, +Inf if not growing",
+		[]string{"nodeID", "logType", "logPart"}, nil,
+	)
 )
 
 // ScrapeNdbinfoLogspaces collects for `ndbinfo.logspaces`
@@ -56,8 +69,18 @@ func (ScrapeNdbinfoLogspaces) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoLogspaces) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoLogspaces) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoLogspaces) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoLogspaces) NdbinfoTables() []string {
+	return []string{"logspaces"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -71,6 +94,7 @@ func (ScrapeNdbinfoLogspaces) Scrape(ctx context.Context, db *sql.DB, ch chan<-
 	var (
 		nodeID, logPart, used, total        uint64
 		logType                             string
+		usage                               []ndbinfoLogUsage
 	)
 
 	// Iterate over the memory settings
@@ -79,13 +103,36 @@ func (ScrapeNdbinfoLogspaces) Scrape(ctx context.Context, db *sql.DB, ch chan<-
 			&nodeID, &logType, &logPart, &total, &used); err != nil {
 			return err
 		}
+		usage = append(usage, ndbinfoLogUsage{
+			labelValues: []string{strconv.FormatUint(nodeID, 10), logType, strconv.FormatUint(logPart, 10)},
+			used:        used,
+			total:       total,
+		})
+	}
+	if err := ndbinfoLogspacesRows.Err(); err != nil {
+		return err
+	}
+	ndbinfoLogFillFromContext(ctx).update("logspaces", time.Now(), usage)
+
+	for _, u := range usage {
 		ch <- prometheus.MustNewConstMetric(
-			ndbinfoLogspacesUsedDesc, prometheus.GaugeValue, float64(used),
-			strconv.FormatUint(nodeID, 10), logType, strconv.FormatUint(logPart, 10))
+			ndbinfoLogspacesUsedDesc, prometheus.GaugeValue, float64(u.used),
+			u.labelValues...)
 
 		ch <- prometheus.MustNewConstMetric(
-			ndbinfoLogspacesTotalDesc, prometheus.GaugeValue, float64(total),
-			strconv.FormatUint(nodeID, 10), logType, strconv.FormatUint(logPart, 10))
+			ndbinfoLogspacesTotalDesc, prometheus.GaugeValue, float64(u.total),
+			u.labelValues...)
+
+		if u.total > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoLogspacesFillRatioDesc, prometheus.GaugeValue, float64(u.used)/float64(u.total),
+				u.labelValues...)
+		}
+		if u.estimated {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoLogspacesSecondsUntilFullDesc, prometheus.GaugeValue, u.secondsUntilFull,
+				u.labelValues...)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,114 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.memory_per_fragment`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ndbinfoMemoryPerFragmentQuery = `
+	SELECT fq_name, IFNULL(parent_fq_name, ''), type, node_id, fragment_num,
+	fixed_elem_count, fixed_elem_alloc_bytes, fixed_elem_free_bytes,
+	var_elem_alloc_bytes, var_elem_free_bytes, hash_index_alloc_bytes
+	FROM ndbinfo.memory_per_fragment;
+	`
+
+// ScrapeNdbinfoMemoryPerFragment collects for `ndbinfo.memory_per_fragment`
+type ScrapeNdbinfoMemoryPerFragment struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoMemoryPerFragment) Name() string {
+	return "ndbinfo.memory_per_fragment"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoMemoryPerFragment) Help() string {
+	return "Collect DataMemory usage per table from ndbinfo.memory_per_fragment"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoMemoryPerFragment) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoMemoryPerFragment) NdbVersion() string {
+	return "7.5.4"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoMemoryPerFragment) NdbinfoTables() []string {
+	return []string{"memory_per_fragment"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoMemoryPerFragment) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	filter, err := newNdbinfoFragmentFilter()
+	if err != nil {
+		return err
+	}
+
+	ndbinfoMemoryPerFragmentRows, err := db.QueryContext(ctx, ndbinfoMemoryPerFragmentQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoMemoryPerFragmentRows.Close()
+
+	var (
+		fqName, parentFqName, fragmentType string
+		nodeID, fragmentNum                uint64
+		fixedCount, fixedAlloc, fixedFree  float64
+		varAlloc, varFree, hashIndexAlloc  float64
+	)
+
+	stats := newNdbinfoFragmentStats()
+	// Iterate over the fragment replicas
+	for ndbinfoMemoryPerFragmentRows.Next() {
+		if err := ndbinfoMemoryPerFragmentRows.Scan(
+			&fqName, &parentFqName, &fragmentType, &nodeID, &fragmentNum,
+			&fixedCount, &fixedAlloc, &fixedFree,
+			&varAlloc, &varFree, &hashIndexAlloc); err != nil {
+			return err
+		}
+		if !filter.match(fqName, parentFqName) {
+			continue
+		}
+		stats.add(fqName, parentFqName, fragmentType, nodeID, fragmentNum,
+			fixedCount, fixedAlloc, fixedFree, varAlloc, varFree, hashIndexAlloc)
+	}
+	if err := ndbinfoMemoryPerFragmentRows.Err(); err != nil {
+		return err
+	}
+
+	stats.collect(ch,
+		[]*prometheus.Desc{
+			newNdbinfoFragmentDesc("memory_per_fragment_rows", "Number of rows stored in the fixed size part of the fragments"),
+			newNdbinfoFragmentDesc("memory_per_fragment_fixed_alloc_bytes", "Bytes of DataMemory allocated for the fixed size part of the fragments"),
+			newNdbinfoFragmentDesc("memory_per_fragment_fixed_free_bytes", "Bytes of free space in the DataMemory allocated for the fixed size part of the fragments"),
+			newNdbinfoFragmentDesc("memory_per_fragment_var_alloc_bytes", "Bytes of DataMemory allocated for the variable size part of the fragments"),
+			newNdbinfoFragmentDesc("memory_per_fragment_var_free_bytes", "Bytes of free space in the DataMemory allocated for the variable size part of the fragments"),
+			newNdbinfoFragmentDesc("memory_per_fragment_hash_index_alloc_bytes", "Bytes of memory allocated for the hash index of the fragments"),
+		},
+		[]prometheus.ValueType{
+			prometheus.GaugeValue, prometheus.GaugeValue, prometheus.GaugeValue,
+			prometheus.GaugeValue, prometheus.GaugeValue, prometheus.GaugeValue,
+		},
+	)
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// The usage columns can be NULL, the metrics of NULL values are skipped.
 const ndbinfoMemoryusageQuery = `
 	SELECT node_id, memory_type, used, used_pages, total, total_pages 
 	FROM ndbinfo.memoryusage;
@@ -68,8 +69,18 @@ func (ScrapeNdbinfoMemoryusage) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoMemoryusage) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoMemoryusage) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoMemoryusage) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoMemoryusage) NdbinfoTables() []string {
+	return []string{"memoryusage"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -81,8 +92,9 @@ func (ScrapeNdbinfoMemoryusage) Scrape(ctx context.Context, db *sql.DB, ch chan<
 	defer ndbinfoMemoryusageRows.Close()
 
 	var (
-		nodeID, used, total, usedPages, totalPages uint64
-		memoryType                                 string
+		nodeID                             uint64
+		used, total, usedPages, totalPages sql.NullInt64
+		memoryType                         string
 	)
 
 	// Iterate over the memory settings
@@ -92,18 +104,22 @@ func (ScrapeNdbinfoMemoryusage) Scrape(ctx context.Context, db *sql.DB, ch chan<
 			&usedPages, &total, &totalPages); err != nil {
 			return err
 		}
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoMemoryusageUsedDesc, prometheus.GaugeValue, float64(used),
-			strconv.FormatUint(nodeID, 10), memoryType)
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoMemoryusageTotalDesc, prometheus.GaugeValue, float64(total),
-			strconv.FormatUint(nodeID, 10), memoryType)
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoMemoryusagePagesDesc, prometheus.GaugeValue, float64(usedPages),
-			strconv.FormatUint(nodeID, 10), memoryType)
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoMemoryusageTotalPagesDesc, prometheus.GaugeValue, float64(totalPages),
-			strconv.FormatUint(nodeID, 10), memoryType)
+		for _, m := range []struct {
+			desc  *prometheus.Desc
+			value sql.NullInt64
+		}{
+			{ndbinfoMemoryusageUsedDesc, used},
+			{ndbinfoMemoryusageTotalDesc, total},
+			{ndbinfoMemoryusagePagesDesc, usedPages},
+			{ndbinfoMemoryusageTotalPagesDesc, totalPages},
+		} {
+			if !m.value.Valid {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				m.desc, prometheus.GaugeValue, float64(m.value.Int64),
+				strconv.FormatUint(nodeID, 10), memoryType)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,195 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.nodes`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ndbinfoNodesQuery = `
+	SELECT node_id, uptime, status, start_phase, config_generation
+	FROM ndbinfo.nodes;
+	`
+
+// ndbinfoNodeStatuses lists the data node statuses reported by ndbinfo.nodes.
+var ndbinfoNodeStatuses = []string{
+	"NOTHING", "CMVMI", "STARTING", "STARTED", "SINGLEUSER",
+	"STOPPING_1", "STOPPING_2", "STOPPING_3", "STOPPING_4", "UNKNOWN",
+}
+
+var (
+	ndbinfoNodesStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "node_status"),
+		"Status of each data node, 1 for the current status and 0 for all others",
+		[]string{"nodeID", "status"}, nil,
+	)
+	ndbinfoNodesUptimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "node_uptime_seconds"),
+		"Time since each data node was last started",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoNodesStartPhaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "node_start_phase"),
+		"Current start phase of each data node, 0 once started",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoNodesConfigGenerationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "node_config_generation"),
+		"Version of the cluster configuration in use by each data node",
+		[]string{"nodeID"}, nil,
+	)
+	ndbinfoNodesRestartsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "node_restarts_total"),
+		"Number of times the uptime of each data node went backwards between scrapes",
+		[]string{"nodeID"}, nil,
+	)
+)
+
+// ndbinfoNodeRestarts counts the restarts of the data nodes of the cluster
+// behind a Pool, detected from their uptime going backwards between scrapes.
+type ndbinfoNodeRestarts struct {
+	mu       sync.Mutex
+	uptimes  map[uint64]uint64
+	restarts map[uint64]uint64
+}
+
+func newNdbinfoNodeRestarts() *ndbinfoNodeRestarts {
+	return &ndbinfoNodeRestarts{uptimes: map[uint64]uint64{}, restarts: map[uint64]uint64{}}
+}
+
+// ndbinfoNodeRestartsFromContext returns the restart counts of the Pool being
+// scraped. A scraper run without a Pool sees every node for the first time.
+func ndbinfoNodeRestartsFromContext(ctx context.Context) *ndbinfoNodeRestarts {
+	if p := poolFromContext(ctx); p != nil {
+		return p.nodeRestarts
+	}
+	return newNdbinfoNodeRestarts()
+}
+
+// update records the uptime of each node and returns the restarts counted so far.
+func (r *ndbinfoNodeRestarts) update(uptimes map[uint64]uint64) map[uint64]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restarts := make(map[uint64]uint64, len(uptimes))
+	for nodeID, uptime := range uptimes {
+		if previous, ok := r.uptimes[nodeID]; ok && uptime < previous {
+			r.restarts[nodeID]++
+		}
+		r.uptimes[nodeID] = uptime
+		restarts[nodeID] = r.restarts[nodeID]
+	}
+	return restarts
+}
+
+// ScrapeNdbinfoNodes collects for `ndbinfo.nodes`
+type ScrapeNdbinfoNodes struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoNodes) Name() string {
+	return "ndbinfo.nodes"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoNodes) Help() string {
+	return "Collect data node status, uptime and restarts from ndbinfo.nodes"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoNodes) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoNodes) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoNodes) NdbinfoTables() []string {
+	return []string{"nodes"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoNodes) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoNodesRows, err := db.QueryContext(ctx, ndbinfoNodesQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoNodesRows.Close()
+
+	type nodeRow struct {
+		nodeID, uptime, startPhase, configGeneration uint64
+		status                                       string
+	}
+	var nodes []nodeRow
+	uptimes := map[uint64]uint64{}
+
+	// Iterate over the data nodes
+	for ndbinfoNodesRows.Next() {
+		var n nodeRow
+		if err := ndbinfoNodesRows.Scan(
+			&n.nodeID, &n.uptime, &n.status, &n.startPhase, &n.configGeneration); err != nil {
+			return err
+		}
+		nodes = append(nodes, n)
+		uptimes[n.nodeID] = n.uptime
+	}
+	if err := ndbinfoNodesRows.Err(); err != nil {
+		return err
+	}
+	restarts := ndbinfoNodeRestartsFromContext(ctx).update(uptimes)
+
+	for _, n := range nodes {
+		node := strconv.FormatUint(n.nodeID, 10)
+
+		known := false
+		for _, s := range ndbinfoNodeStatuses {
+			value := 0.0
+			if s == n.status {
+				value = 1
+				known = true
+			}
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoNodesStatusDesc, prometheus.GaugeValue, value,
+				node, s)
+		}
+		if !known {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoNodesStatusDesc, prometheus.GaugeValue, 1,
+				node, n.status)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoNodesUptimeDesc, prometheus.GaugeValue, float64(n.uptime),
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoNodesStartPhaseDesc, prometheus.GaugeValue, float64(n.startPhase),
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoNodesConfigGenerationDesc, prometheus.GaugeValue, float64(n.configGeneration),
+			node)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoNodesRestartsDesc, prometheus.CounterValue, float64(restarts[n.nodeID]),
+			node)
+	}
+	return nil
+}
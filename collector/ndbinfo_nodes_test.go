@@ -0,0 +1,90 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestScrapeNdbinfoNodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"node_id", "uptime", "status", "start_phase", "config_generation"}
+	scrapes := []struct {
+		uptime   uint64
+		status   string
+		restarts float64
+	}{
+		{1000, "STARTED", 0},
+		{1015, "STARTED", 0},
+		{3, "STARTING", 1},
+		{20, "STARTED", 1},
+		{2, "RESTARTING", 2},
+	}
+
+	convey.Convey("Restarts are counted when uptime goes backwards", t, func() {
+		pool := NewPool(dsn)
+		for i, s := range scrapes {
+			// The history survives a reconnect, which replaces the handle.
+			if i == 3 {
+				pool.Close()
+			}
+			mock.ExpectQuery(sanitizeQuery(ndbinfoNodesQuery)).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(1, s.uptime, s.status, 0, 4))
+
+			ch := make(chan prometheus.Metric)
+			go func() {
+				if err := (ScrapeNdbinfoNodes{}).Scrape(withPool(context.Background(), pool), db, ch); err != nil {
+					t.Errorf("error calling function on test: %s", err)
+				}
+				close(ch)
+			}()
+
+			status := map[string]float64{}
+			var got []MetricResult
+			for m := range ch {
+				r := readMetric(m)
+				if s, ok := r.labels["status"]; ok {
+					status[s] = r.value
+					continue
+				}
+				got = append(got, r)
+			}
+
+			convey.So(status[s.status], convey.ShouldEqual, 1)
+			convey.So(status["STOPPING_1"], convey.ShouldEqual, 0)
+			convey.So(got, convey.ShouldResemble, []MetricResult{
+				{labels: labelMap{"nodeID": "1"}, value: float64(s.uptime), metricType: dto.MetricType_GAUGE},
+				{labels: labelMap{"nodeID": "1"}, value: 0, metricType: dto.MetricType_GAUGE},
+				{labels: labelMap{"nodeID": "1"}, value: 4, metricType: dto.MetricType_GAUGE},
+				{labels: labelMap{"nodeID": "1"}, value: s.restarts, metricType: dto.MetricType_COUNTER},
+			})
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.operations_per_fragment`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ndbinfoOperationsPerFragmentQuery = `
+	SELECT fq_name, IFNULL(parent_fq_name, ''), type, node_id, fragment_num,
+	tot_key_reads, tot_key_inserts, tot_key_updates, tot_key_writes, tot_key_deletes,
+	tot_frag_scans, tot_scan_rows_examined, tot_scan_rows_returned,
+	tot_commits, conc_frag_scans
+	FROM ndbinfo.operations_per_fragment;
+	`
+
+// ScrapeNdbinfoOperationsPerFragment collects for `ndbinfo.operations_per_fragment`
+type ScrapeNdbinfoOperationsPerFragment struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoOperationsPerFragment) Name() string {
+	return "ndbinfo.operations_per_fragment"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoOperationsPerFragment) Help() string {
+	return "Collect key and scan operations per table from ndbinfo.operations_per_fragment"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoOperationsPerFragment) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoOperationsPerFragment) NdbVersion() string {
+	return "7.5.4"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoOperationsPerFragment) NdbinfoTables() []string {
+	return []string{"operations_per_fragment"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoOperationsPerFragment) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	filter, err := newNdbinfoFragmentFilter()
+	if err != nil {
+		return err
+	}
+
+	ndbinfoOperationsPerFragmentRows, err := db.QueryContext(ctx, ndbinfoOperationsPerFragmentQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoOperationsPerFragmentRows.Close()
+
+	var (
+		fqName, parentFqName, fragmentType            string
+		nodeID, fragmentNum                           uint64
+		keyReads, keyInserts, keyUpdates              float64
+		keyWrites, keyDeletes                         float64
+		fragScans, scanRowsExamined, scanRowsReturned float64
+		commits, concFragScans                        float64
+	)
+
+	stats := newNdbinfoFragmentStats()
+	// Iterate over the fragment replicas
+	for ndbinfoOperationsPerFragmentRows.Next() {
+		if err := ndbinfoOperationsPerFragmentRows.Scan(
+			&fqName, &parentFqName, &fragmentType, &nodeID, &fragmentNum,
+			&keyReads, &keyInserts, &keyUpdates, &keyWrites, &keyDeletes,
+			&fragScans, &scanRowsExamined, &scanRowsReturned,
+			&commits, &concFragScans); err != nil {
+			return err
+		}
+		if !filter.match(fqName, parentFqName) {
+			continue
+		}
+		stats.add(fqName, parentFqName, fragmentType, nodeID, fragmentNum,
+			keyReads, keyInserts, keyUpdates, keyWrites, keyDeletes,
+			fragScans, scanRowsExamined, scanRowsReturned,
+			commits, concFragScans)
+	}
+	if err := ndbinfoOperationsPerFragmentRows.Err(); err != nil {
+		return err
+	}
+
+	stats.collect(ch,
+		[]*prometheus.Desc{
+			newNdbinfoFragmentDesc("operations_per_fragment_key_reads_total", "Number of key read requests on the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_key_inserts_total", "Number of key insert requests on the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_key_updates_total", "Number of key update requests on the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_key_writes_total", "Number of key write requests on the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_key_deletes_total", "Number of key delete requests on the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_scans_total", "Number of scans of the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_scan_rows_examined_total", "Number of rows examined by scans of the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_scan_rows_returned_total", "Number of rows returned to the client by scans of the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_commits_total", "Number of row changes committed to the fragments"),
+			newNdbinfoFragmentDesc("operations_per_fragment_concurrent_scans", "Number of scans currently active on the fragments"),
+		},
+		[]prometheus.ValueType{
+			prometheus.CounterValue, prometheus.CounterValue, prometheus.CounterValue,
+			prometheus.CounterValue, prometheus.CounterValue, prometheus.CounterValue,
+			prometheus.CounterValue, prometheus.CounterValue, prometheus.CounterValue,
+			prometheus.GaugeValue,
+		},
+	)
+	return nil
+}
@@ -24,35 +24,12 @@ import (
 )
 
 const ndbinfoPgmanTimeTrackQuery = `
-        SELECT node_id, upper_bound, sum(page_reads), sum(page_writes),
-        sum(log_waits), sum(get_page)
-        FROM ndbinfo.pgman_time_track_stats
-        GROUP BY node_id, upper_bound;
+	SELECT node_id, block_instance, upper_bound, sum(page_reads), sum(page_writes),
+	sum(log_waits), sum(get_page)
+	FROM ndbinfo.pgman_time_track_stats
+	GROUP BY node_id, block_instance, upper_bound;
 	`
 
-var (
-	ndbinfoPgmanTimeTrackPageReadsDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "pgman_time_track_page_reads"),
-		"Time track of page reads",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoPgmanTimeTrackPageWritesDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "pgman_time_track_page_writes"),
-		"Time track of page writes",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoPgmanTimeTrackLogWaitsDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "pgman_time_track_log_waits"),
-		"Time track of wait for UNDO log writes",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoPgmanTimeTrackGetPageDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "pgman_time_track_get_page"),
-		"Time track of get_page operation",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-)
-
 // ScrapeNdbinfoPgmanTimeTrack collects for `ndbinfo.pgman_time_track_stats`
 type ScrapeNdbinfoPgmanTimeTrack struct{}
 
@@ -67,8 +44,18 @@ func (ScrapeNdbinfoPgmanTimeTrack) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoPgmanTimeTrack) Version() float64 {
-	return 5.7
+func (ScrapeNdbinfoPgmanTimeTrack) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoPgmanTimeTrack) NdbVersion() string {
+	return "7.6.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoPgmanTimeTrack) NdbinfoTables() []string {
+	return []string{"pgman_time_track_stats"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -80,33 +67,34 @@ func (ScrapeNdbinfoPgmanTimeTrack) Scrape(ctx context.Context, db *sql.DB, ch ch
 	defer ndbinfoPgmanTimeTrackRows.Close()
 
 	var (
-		nodeID, upper_bound, page_reads     uint64
-                page_writes, log_waits              uint64
-                get_page                            float64
+		nodeID, blockInstance, upperBound uint64
+		pageReads, pageWrites, logWaits   uint64
+		getPage                           uint64
 	)
 
-	// Iterate over the memory settings
+	timeTrack := newNdbinfoTimeTrack()
+	// Iterate over the buckets of each node and block instance
 	for ndbinfoPgmanTimeTrackRows.Next() {
 		if err := ndbinfoPgmanTimeTrackRows.Scan(
-			&nodeID, &upper_bound, &page_reads, &page_writes,
-                        &log_waits, &get_page); err != nil {
+			&nodeID, &blockInstance, &upperBound, &pageReads, &pageWrites,
+			&logWaits, &getPage); err != nil {
 			return err
 		}
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoPgmanTimeTrackPageReadsDesc, prometheus.GaugeValue, float64(page_reads),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoPgmanTimeTrackPageWritesDesc, prometheus.GaugeValue, float64(page_writes),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoPgmanTimeTrackLogWaitsDesc, prometheus.GaugeValue, float64(log_waits),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoPgmanTimeTrackGetPageDesc, prometheus.GaugeValue, float64(get_page),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
+		labelValues := []string{strconv.FormatUint(nodeID, 10)}
+		if *ndbinfoTimeTrackByBlockInstance {
+			labelValues = append(labelValues, strconv.FormatUint(blockInstance, 10))
+		}
+		timeTrack.add(labelValues, upperBound, pageReads, pageWrites, logWaits, getPage)
 	}
+	if err := ndbinfoPgmanTimeTrackRows.Err(); err != nil {
+		return err
+	}
+
+	timeTrack.collect(ch,
+		newNdbinfoTimeTrackDesc("pgman_time_track_page_reads_seconds", "Histogram of disk data page read durations"),
+		newNdbinfoTimeTrackDesc("pgman_time_track_page_writes_seconds", "Histogram of disk data page write durations"),
+		newNdbinfoTimeTrackDesc("pgman_time_track_log_waits_seconds", "Histogram of waits for UNDO log writes"),
+		newNdbinfoTimeTrackDesc("pgman_time_track_get_page_seconds", "Histogram of get_page operation durations"),
+	)
 	return nil
 }
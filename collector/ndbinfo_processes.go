@@ -50,8 +50,18 @@ func (ScrapeNdbinfoProcesses) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoProcesses) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoProcesses) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoProcesses) NdbVersion() string {
+	return "7.5.7"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoProcesses) NdbinfoTables() []string {
+	return []string{"processes"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -63,8 +63,18 @@ func (ScrapeNdbinfoResources) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoResources) Version() float64 {
-	return 5.7
+func (ScrapeNdbinfoResources) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoResources) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoResources) NdbinfoTables() []string {
+	return []string{"resources"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
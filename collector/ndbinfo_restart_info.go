@@ -0,0 +1,153 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.restart_info`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The start of the last restart is derived from the uptime in ndbinfo.nodes,
+// using the clock of the SQL node. The type of the restart (initial, node or
+// system) is not exposed by ndbinfo, so the metrics have no label for it.
+const ndbinfoRestartInfoQuery = `
+	SELECT r.node_id, r.node_restart_status, r.node_restart_status_int,
+	r.secs_to_complete_node_failure, r.secs_to_allocate_node_id,
+	r.secs_to_include_in_heartbeat_protocol, r.secs_until_wait_for_ndbcntr_master,
+	r.secs_wait_for_ndbcntr_master, r.secs_to_get_start_permitted,
+	r.secs_to_wait_for_lcp_for_copy_meta_data, r.secs_to_copy_meta_data,
+	r.secs_to_include_node, r.secs_starting_node_to_request_local_recovery,
+	r.secs_for_local_recovery, r.secs_restore_fragments, r.secs_undo_disk_data,
+	r.secs_exec_redo_log, r.secs_index_rebuild, r.secs_to_synchronize_starting_node,
+	r.secs_wait_lcp_for_restart, r.secs_wait_subscription_handover,
+	r.total_restart_secs, UNIX_TIMESTAMP() - n.uptime
+	FROM ndbinfo.restart_info r
+	LEFT JOIN ndbinfo.nodes n ON r.node_id = n.node_id;
+	`
+
+// ndbinfoRestartPhases lists the restart phases in the order of their secs_* columns.
+var ndbinfoRestartPhases = []string{
+	"to_complete_node_failure", "to_allocate_node_id",
+	"to_include_in_heartbeat_protocol", "until_wait_for_ndbcntr_master",
+	"wait_for_ndbcntr_master", "to_get_start_permitted",
+	"to_wait_for_lcp_for_copy_meta_data", "to_copy_meta_data",
+	"to_include_node", "starting_node_to_request_local_recovery",
+	"for_local_recovery", "restore_fragments", "undo_disk_data",
+	"exec_redo_log", "index_rebuild", "to_synchronize_starting_node",
+	"wait_lcp_for_restart", "wait_subscription_handover",
+}
+
+var (
+	ndbinfoRestartStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "restart_status"),
+		"Status of the last restart of each data node as reported by node_restart_status_int",
+		[]string{"nodeID", "nodeRestartStatus"}, nil,
+	)
+	ndbinfoRestartTotalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "restart_duration_seconds"),
+		"Total time taken by the last restart of each data node",
+		[]string{"nodeID", "nodeRestartStatus"}, nil,
+	)
+	ndbinfoRestartPhaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "restart_phase_duration_seconds"),
+		"Time taken by each phase of the last restart of each data node",
+		[]string{"nodeID", "nodeRestartStatus", "phase"}, nil,
+	)
+	ndbinfoRestartTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "restart_last_timestamp_seconds"),
+		"Unix timestamp at which each data node was last started",
+		[]string{"nodeID"}, nil,
+	)
+)
+
+// ScrapeNdbinfoRestartInfo collects for `ndbinfo.restart_info`
+type ScrapeNdbinfoRestartInfo struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoRestartInfo) Name() string {
+	return "ndbinfo.restart_info"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoRestartInfo) Help() string {
+	return "Collect the duration of the last restart of each data node and its phases from ndbinfo.restart_info"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoRestartInfo) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoRestartInfo) NdbVersion() string {
+	return "7.4.2"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoRestartInfo) NdbinfoTables() []string {
+	return []string{"restart_info", "nodes"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoRestartInfo) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoRestartInfoRows, err := db.QueryContext(ctx, ndbinfoRestartInfoQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoRestartInfoRows.Close()
+
+	var (
+		nodeID, statusInt, totalSecs uint64
+		status                       string
+		phaseSecs                    = make([]uint64, len(ndbinfoRestartPhases))
+		startTimestamp               sql.NullInt64
+	)
+	scanArgs := []interface{}{&nodeID, &status, &statusInt}
+	for i := range phaseSecs {
+		scanArgs = append(scanArgs, &phaseSecs[i])
+	}
+	scanArgs = append(scanArgs, &totalSecs, &startTimestamp)
+
+	// Iterate over the data nodes
+	for ndbinfoRestartInfoRows.Next() {
+		if err := ndbinfoRestartInfoRows.Scan(scanArgs...); err != nil {
+			return err
+		}
+		node := strconv.FormatUint(nodeID, 10)
+
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoRestartStatusDesc, prometheus.GaugeValue, float64(statusInt),
+			node, status)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoRestartTotalDesc, prometheus.GaugeValue, float64(totalSecs),
+			node, status)
+		for i, phase := range ndbinfoRestartPhases {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoRestartPhaseDesc, prometheus.GaugeValue, float64(phaseSecs[i]),
+				node, status, phase)
+		}
+		// The node is not known to ndbinfo.nodes while it is down.
+		if startTimestamp.Valid {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoRestartTimestampDesc, prometheus.GaugeValue, float64(startTimestamp.Int64),
+				node)
+		}
+	}
+	return ndbinfoRestartInfoRows.Err()
+}
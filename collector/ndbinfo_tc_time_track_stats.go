@@ -24,40 +24,12 @@ import (
 )
 
 const ndbinfoTcTimeTrackQuery = `
-        SELECT node_id, upper_bound, sum(scans), sum(transactions), sum(read_key_ops),
-        sum(write_key_ops), sum(index_key_ops)
-        FROM ndbinfo.tc_time_track_stats
-        GROUP BY node_id, upper_bound;
+	SELECT node_id, block_instance, upper_bound, sum(scans), sum(transactions),
+	sum(read_key_ops), sum(write_key_ops), sum(index_key_ops)
+	FROM ndbinfo.tc_time_track_stats
+	GROUP BY node_id, block_instance, upper_bound;
 	`
 
-var (
-	ndbinfoTcTimeTrackScansDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "tc_time_track_scans"),
-		"Time track of scans",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoTcTimeTrackTransactionsDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "tc_time_track_transactions"),
-		"Time track of transactions",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoTcTimeTrackReadKeyDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "tc_time_track_read_key"),
-		"Time track of read key operations",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoTcTimeTrackWriteKeyDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "tc_time_track_write_key"),
-		"Time track of write key operations",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-	ndbinfoTcTimeTrackIndexKeyDesc = prometheus.NewDesc(
-		prometheus.BuildFQName("ndb", ndbinfo, "tc_time_track_index_key"),
-		"Time track of index key operations",
-		[]string{"nodeID", "upperBound"}, nil,
-	)
-)
-
 // ScrapeNdbinfoTcTimeTrack collects for `ndbinfo.tc_time_track_stats`
 type ScrapeNdbinfoTcTimeTrack struct{}
 
@@ -72,8 +44,18 @@ func (ScrapeNdbinfoTcTimeTrack) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoTcTimeTrack) Version() float64 {
-	return 5.7
+func (ScrapeNdbinfoTcTimeTrack) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoTcTimeTrack) NdbVersion() string {
+	return "7.6.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoTcTimeTrack) NdbinfoTables() []string {
+	return []string{"tc_time_track_stats"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -85,38 +67,35 @@ func (ScrapeNdbinfoTcTimeTrack) Scrape(ctx context.Context, db *sql.DB, ch chan<
 	defer ndbinfoTcTimeTrackRows.Close()
 
 	var (
-		nodeID, upper_bound, scans          uint64
-                transactions, read_key_ops          uint64
-                write_key_ops, index_key_ops        uint64
+		nodeID, blockInstance, upperBound, scans uint64
+		transactions, readKeyOps                 uint64
+		writeKeyOps, indexKeyOps                 uint64
 	)
 
-	// Iterate over the memory settings
+	timeTrack := newNdbinfoTimeTrack()
+	// Iterate over the buckets of each node and block instance
 	for ndbinfoTcTimeTrackRows.Next() {
 		if err := ndbinfoTcTimeTrackRows.Scan(
-			&nodeID, &upper_bound, &scans, &transactions,
-                        &read_key_ops, &write_key_ops, &index_key_ops); err != nil {
+			&nodeID, &blockInstance, &upperBound, &scans, &transactions,
+			&readKeyOps, &writeKeyOps, &indexKeyOps); err != nil {
 			return err
 		}
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTcTimeTrackScansDesc, prometheus.GaugeValue, float64(scans),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTcTimeTrackTransactionsDesc, prometheus.GaugeValue, float64(transactions),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTcTimeTrackReadKeyDesc, prometheus.GaugeValue, float64(read_key_ops),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTcTimeTrackWriteKeyDesc, prometheus.GaugeValue, float64(write_key_ops),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTcTimeTrackIndexKeyDesc, prometheus.GaugeValue, float64(index_key_ops),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(upper_bound, 10))
-
+		labelValues := []string{strconv.FormatUint(nodeID, 10)}
+		if *ndbinfoTimeTrackByBlockInstance {
+			labelValues = append(labelValues, strconv.FormatUint(blockInstance, 10))
+		}
+		timeTrack.add(labelValues, upperBound, scans, transactions, readKeyOps, writeKeyOps, indexKeyOps)
+	}
+	if err := ndbinfoTcTimeTrackRows.Err(); err != nil {
+		return err
 	}
+
+	timeTrack.collect(ch,
+		newNdbinfoTimeTrackDesc("tc_time_track_scans_seconds", "Histogram of scan durations as tracked by TC"),
+		newNdbinfoTimeTrackDesc("tc_time_track_transactions_seconds", "Histogram of transaction durations as tracked by TC"),
+		newNdbinfoTimeTrackDesc("tc_time_track_read_key_seconds", "Histogram of read key operation durations as tracked by TC"),
+		newNdbinfoTimeTrackDesc("tc_time_track_write_key_seconds", "Histogram of write key operation durations as tracked by TC"),
+		newNdbinfoTimeTrackDesc("tc_time_track_index_key_seconds", "Histogram of index key operation durations as tracked by TC"),
+	)
 	return nil
 }
@@ -0,0 +1,88 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestScrapeNdbinfoTcTimeTrack(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"node_id", "block_instance", "upper_bound", "sum(scans)", "sum(transactions)",
+		"sum(read_key_ops)", "sum(write_key_ops)", "sum(index_key_ops)"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(1, 0, 50, 0, 10, 100, 4, 0).
+		AddRow(1, 0, 100, 1, 5, 20, 2, 0).
+		AddRow(1, 1, 50, 0, 2, 10, 0, 0).
+		AddRow(1, 1, 100, 0, 1, 2, 0, 0)
+	mock.ExpectQuery(sanitizeQuery(ndbinfoTcTimeTrackQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = (ScrapeNdbinfoTcTimeTrack{}).Scrape(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	// Block instances are summed per node and upper bounds become cumulative buckets in seconds.
+	expected := []struct {
+		count   uint64
+		sum     float64
+		buckets map[float64]uint64
+	}{
+		{1, 0.000075, map[float64]uint64{0.00005: 0, 0.0001: 1}},
+		{18, 0.00075, map[float64]uint64{0.00005: 12, 0.0001: 18}},
+		{132, 0.0044, map[float64]uint64{0.00005: 110, 0.0001: 132}},
+		{6, 0.00025, map[float64]uint64{0.00005: 4, 0.0001: 6}},
+		{0, 0, map[float64]uint64{0.00005: 0, 0.0001: 0}},
+	}
+	convey.Convey("Histogram comparison", t, func() {
+		for _, expect := range expected {
+			got := &dto.Metric{}
+			convey.So((<-ch).Write(got), convey.ShouldBeNil)
+			convey.So(got.GetLabel(), convey.ShouldHaveLength, 1)
+			convey.So(got.GetLabel()[0].GetValue(), convey.ShouldEqual, "1")
+			convey.So(got.GetHistogram().GetSampleCount(), convey.ShouldEqual, expect.count)
+			convey.So(got.GetHistogram().GetSampleSum(), convey.ShouldAlmostEqual, expect.sum, 1e-9)
+			for _, b := range got.GetHistogram().GetBucket() {
+				convey.So(b.GetCumulativeCount(), convey.ShouldEqual, expect.buckets[b.GetUpperBound()])
+			}
+		}
+		_, ok := <-ch
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
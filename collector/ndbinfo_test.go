@@ -0,0 +1,1028 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestIsNdbinfoReporter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	cases := []struct {
+		name       string
+		nodeID     interface{}
+		reporterID interface{}
+		expected   bool
+	}{
+		{"lowest SQL node", 51, 51, true},
+		{"other SQL node", 52, 51, false},
+		{"not connected to the cluster", 0, 51, false},
+		{"no SQL nodes in ndbinfo.processes", 51, nil, false},
+	}
+	convey.Convey("Designated ndbinfo reporter", t, func() {
+		for _, c := range cases {
+			mock.ExpectQuery(sanitizeQuery(ndbNodeIDQuery)).
+				WillReturnRows(sqlmock.NewRows([]string{"@@ndb_nodeid"}).AddRow(c.nodeID))
+			mock.ExpectQuery(sanitizeQuery(ndbinfoReporterQuery)).
+				WillReturnRows(sqlmock.NewRows([]string{"MIN(node_id)"}).AddRow(c.reporterID))
+
+			got, err := isNdbinfoReporter(context.Background(), db)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(got, convey.ShouldEqual, c.expected)
+		}
+	})
+
+	convey.Convey("ndbinfo scrapers", t, func() {
+		convey.So(isNdbinfoScraper(ScrapeNdbinfoMemoryusage{}), convey.ShouldBeTrue)
+		convey.So(isNdbinfoScraper(ScrapeGlobalStatus{}), convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
+
+// ndbinfoQueryFixture is a recorded result set returned for one query of a scraper.
+type ndbinfoQueryFixture struct {
+	query   string
+	columns []string
+	rows    [][]driver.Value
+}
+
+// ndbinfoFixture describes the expected outcome of a scraper for recorded result sets.
+type ndbinfoFixture struct {
+	name     string
+	scraper  Scraper
+	queries  []ndbinfoQueryFixture
+	expected []MetricResult
+	wantErr  bool
+}
+
+// ndbinfoMetric builds an expected metric from label name/value pairs.
+func ndbinfoMetric(metricType dto.MetricType, value float64, labels ...string) MetricResult {
+	m := MetricResult{labels: labelMap{}, value: value, metricType: metricType}
+	for i := 0; i+1 < len(labels); i += 2 {
+		m.labels[labels[i]] = labels[i+1]
+	}
+	return m
+}
+
+func ndbinfoGauge(value float64, labels ...string) MetricResult {
+	return ndbinfoMetric(dto.MetricType_GAUGE, value, labels...)
+}
+
+func ndbinfoCounter(value float64, labels ...string) MetricResult {
+	return ndbinfoMetric(dto.MetricType_COUNTER, value, labels...)
+}
+
+func ndbinfoHistogram(count float64, labels ...string) MetricResult {
+	return ndbinfoMetric(dto.MetricType_HISTOGRAM, count, labels...)
+}
+
+var (
+	ndbinfoMemoryusageColumns   = []string{"node_id", "memory_type", "used", "used_pages", "total", "total_pages"}
+	ndbinfoResourcesColumns     = []string{"node_id", "resource_name", "reserved", "used"}
+	ndbinfoLongSignalColumns    = []string{"node_id", "used_pages", "total_pages"}
+	ndbinfoLogColumns           = []string{"node_id", "log_type", "log_part", "total", "used"}
+	ndbinfoCountersColumns      = []string{"node_id", "counter_name", "sum(val)"}
+	ndbinfoTransportersColumns  = []string{"node_id", "remote_node_id", "status", "remote_address", "bytes_sent", "bytes_received", "connect_count", "overloaded", "overload_count", "slowdown", "slowdown_count"}
+	ndbinfoPgmanTimeTrackColums = []string{"node_id", "block_instance", "upper_bound", "sum(page_reads)", "sum(page_writes)", "sum(log_waits)", "sum(get_page)"}
+	ndbinfoMemoryPerFragColumns = []string{"fq_name", "parent_fq_name", "type", "node_id", "fragment_num", "fixed_elem_count",
+		"fixed_elem_alloc_bytes", "fixed_elem_free_bytes", "var_elem_alloc_bytes", "var_elem_free_bytes", "hash_index_alloc_bytes"}
+	ndbinfoOperationsPerFragColumns = []string{"fq_name", "parent_fq_name", "type", "node_id", "fragment_num",
+		"tot_key_reads", "tot_key_inserts", "tot_key_updates", "tot_key_writes", "tot_key_deletes",
+		"tot_frag_scans", "tot_scan_rows_examined", "tot_scan_rows_returned", "tot_commits", "conc_frag_scans"}
+	ndbinfoRestartInfoColumns = []string{"node_id", "node_restart_status", "node_restart_status_int",
+		"secs_to_complete_node_failure", "secs_to_allocate_node_id", "secs_to_include_in_heartbeat_protocol",
+		"secs_until_wait_for_ndbcntr_master", "secs_wait_for_ndbcntr_master", "secs_to_get_start_permitted",
+		"secs_to_wait_for_lcp_for_copy_meta_data", "secs_to_copy_meta_data", "secs_to_include_node",
+		"secs_starting_node_to_request_local_recovery", "secs_for_local_recovery", "secs_restore_fragments",
+		"secs_undo_disk_data", "secs_exec_redo_log", "secs_index_rebuild", "secs_to_synchronize_starting_node",
+		"secs_wait_lcp_for_restart", "secs_wait_subscription_handover", "total_restart_secs", "UNIX_TIMESTAMP() - n.uptime"}
+	ndbinfoDiskWriteSpeedBaseColumns = []string{"node_id", "SUM(backup_lcp_bytes_written)", "SUM(redo_bytes_written)",
+		"MAX(millis_ago + millis_passed)", "MIN(IF(backup_lcp_bytes_written > 0, millis_ago, NULL))"}
+	ndbinfoClusterLocksColumns = []string{"node_id", "mode", "state", "op", "count(*)", "avg(duration_millis)", "max(duration_millis)",
+		"sum(duration_millis)", "sum(duration_millis <= 1)", "sum(duration_millis <= 10)", "sum(duration_millis <= 100)",
+		"sum(duration_millis <= 1000)", "sum(duration_millis <= 10000)", "sum(duration_millis <= 60000)"}
+	ndbinfoCpustatColumns = []string{"node_id", "thr_no", "thread_name", "avg(OS_user)", "avg(OS_system)", "avg(OS_idle)",
+		"avg(thread_exec)", "avg(thread_sleeping)", "avg(thread_spinning)", "avg(thread_send)", "avg(thread_buffer_full)"}
+)
+
+var ndbinfoFixtures = []ndbinfoFixture{
+	{
+		name:    "memoryusage NDB 7.5",
+		scraper: ScrapeNdbinfoMemoryusage{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryusageQuery, ndbinfoMemoryusageColumns, [][]driver.Value{
+			{1, "Data memory", 1048576, 32, 2097152, 64},
+			{1, "Index memory", 32768, 4, 262144, 32},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1048576, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(2097152, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(32, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(64, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(32768, "nodeID", "1", "memoryType", "Index memory"),
+			ndbinfoGauge(262144, "nodeID", "1", "memoryType", "Index memory"),
+			ndbinfoGauge(4, "nodeID", "1", "memoryType", "Index memory"),
+			ndbinfoGauge(32, "nodeID", "1", "memoryType", "Index memory"),
+		},
+	},
+	{
+		// Index memory is part of the data memory since NDB 7.6.
+		name:    "memoryusage NDB 7.6",
+		scraper: ScrapeNdbinfoMemoryusage{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryusageQuery, ndbinfoMemoryusageColumns, [][]driver.Value{
+			{1, "Data memory", 1048576, 32, 2097152, 64},
+			{1, "Long message buffer", 524288, 2048, 33554432, 131072},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1048576, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(2097152, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(32, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(64, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(524288, "nodeID", "1", "memoryType", "Long message buffer"),
+			ndbinfoGauge(33554432, "nodeID", "1", "memoryType", "Long message buffer"),
+			ndbinfoGauge(2048, "nodeID", "1", "memoryType", "Long message buffer"),
+			ndbinfoGauge(131072, "nodeID", "1", "memoryType", "Long message buffer"),
+		},
+	},
+	{
+		name:    "memoryusage NDB 8.0",
+		scraper: ScrapeNdbinfoMemoryusage{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryusageQuery, ndbinfoMemoryusageColumns, [][]driver.Value{
+			{2, "Long message buffer", 524288, 2048, 67108864, 262144},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(524288, "nodeID", "2", "memoryType", "Long message buffer"),
+			ndbinfoGauge(67108864, "nodeID", "2", "memoryType", "Long message buffer"),
+			ndbinfoGauge(2048, "nodeID", "2", "memoryType", "Long message buffer"),
+			ndbinfoGauge(262144, "nodeID", "2", "memoryType", "Long message buffer"),
+		},
+	},
+	{
+		name:    "memoryusage NULL column",
+		scraper: ScrapeNdbinfoMemoryusage{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryusageQuery, ndbinfoMemoryusageColumns, [][]driver.Value{
+			{1, "Data memory", nil, 32, 2097152, nil},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(2097152, "nodeID", "1", "memoryType", "Data memory"),
+			ndbinfoGauge(32, "nodeID", "1", "memoryType", "Data memory"),
+		},
+	},
+	{
+		name:    "memoryusage unexpected column count",
+		scraper: ScrapeNdbinfoMemoryusage{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryusageQuery, append(ndbinfoMemoryusageColumns, "extra"), [][]driver.Value{
+			{1, "Data memory", 1048576, 32, 2097152, 64, 0},
+		}}},
+		wantErr: true,
+	},
+	{
+		name:    "threadstat NDB 7.6",
+		scraper: ScrapeNdbinfoThreadstat{},
+		queries: []ndbinfoQueryFixture{{ndbinfoThreadstatQuery, []string{"node_id", "thr_no", "thr_nm", "c_loop", "c_exec", "c_wait", "os_tid", "os_now",
+			"os_ru_utime", "os_ru_stime", "os_ru_minflt", "os_ru_majflt", "os_ru_nvcsw", "os_ru_nivcsw"}, [][]driver.Value{
+			{1, 0, "main", "100", "200", "300", 1234, 5000, 10, 20, 1, 0, 5, 6},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(100, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(200, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(300, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(5000, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(10, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(20, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(1, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(0, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(5, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+			ndbinfoCounter(6, "nodeID", "1", "threadNO", "0", "threadName", "main"),
+		},
+	},
+	{
+		// The counters are integers rather than strings in NDB 8.0.
+		name:    "threadstat NDB 8.0",
+		scraper: ScrapeNdbinfoThreadstat{},
+		queries: []ndbinfoQueryFixture{{ndbinfoThreadstatQuery, []string{"node_id", "thr_no", "thr_nm", "c_loop", "c_exec", "c_wait", "os_tid", "os_now",
+			"os_ru_utime", "os_ru_stime", "os_ru_minflt", "os_ru_majflt", "os_ru_nvcsw", "os_ru_nivcsw"}, [][]driver.Value{
+			{2, 1, "ldm", int64(100), int64(200), int64(300), 1235, 5000, 10, 20, 1, 0, 5, 6},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(100, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(200, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(300, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(5000, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(10, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(20, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(1, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(0, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(5, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+			ndbinfoCounter(6, "nodeID", "2", "threadNO", "1", "threadName", "ldm"),
+		},
+	},
+	{
+		name:    "threadstat non-numeric counter",
+		scraper: ScrapeNdbinfoThreadstat{},
+		queries: []ndbinfoQueryFixture{{ndbinfoThreadstatQuery, []string{"node_id", "thr_no", "thr_nm", "c_loop", "c_exec", "c_wait", "os_tid", "os_now",
+			"os_ru_utime", "os_ru_stime", "os_ru_minflt", "os_ru_majflt", "os_ru_nvcsw", "os_ru_nivcsw"}, [][]driver.Value{
+			{1, 0, "main", "n/a", "200", "300", 1234, 5000, 10, 20, 1, 0, 5, 6},
+		}}},
+		wantErr: true,
+	},
+	{
+		name:    "cpustat",
+		scraper: ScrapeNdbinfoCpustat{},
+		queries: []ndbinfoQueryFixture{{fmt.Sprintf(ndbinfoCpustatQuery, "cpustat"), ndbinfoCpustatColumns, [][]driver.Value{
+			{1, 1, "ldm", 85, 5, 10, 80, 15, 2, 3, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(85, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "mode", "user"),
+			ndbinfoGauge(5, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "mode", "system"),
+			ndbinfoGauge(10, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "mode", "idle"),
+			ndbinfoGauge(80, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "state", "exec"),
+			ndbinfoGauge(15, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "state", "sleeping"),
+			ndbinfoGauge(2, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "state", "spinning"),
+			ndbinfoGauge(3, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "state", "send"),
+			ndbinfoGauge(0, "nodeID", "1", "threadNO", "1", "threadName", "ldm", "state", "buffer_full"),
+		},
+	},
+	{
+		name:    "cpustat NULL average",
+		scraper: ScrapeNdbinfoCpustat{},
+		queries: []ndbinfoQueryFixture{{fmt.Sprintf(ndbinfoCpustatQuery, "cpustat"), ndbinfoCpustatColumns, [][]driver.Value{
+			{1, 1, "ldm", nil, 5, 10, 80, 15, 2, 3, 0},
+		}}},
+		wantErr: true,
+	},
+	{
+		name:    "config_values",
+		scraper: ScrapeNdbinfoConfigValues{},
+		queries: []ndbinfoQueryFixture{{ndbinfoConfigValuesQuery, []string{"node_id", "param_name", "param_type", "config_value"}, [][]driver.Value{
+			{1, "DataMemory", "unsigned", "104857600"},
+			{1, "NoOfFragmentLogParts", "unsigned", "4"},
+			{1, "Diskless", "bool", "false"},
+			{1, "StopOnError", "bool", "1"},
+			{1, "DataDir", "string", "/var/lib/mysql-cluster"},
+			{1, "Arbitration", "enum", "Default"},
+			// A CPU list that happens to look like a number.
+			{1, "LockExecuteThreadToCPU", "bitmask", "1"},
+			{1, "LockPagesInMainMemory", "unsigned", nil},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(104857600, "nodeID", "1", "paramName", "DataMemory"),
+			ndbinfoGauge(4, "nodeID", "1", "paramName", "NoOfFragmentLogParts"),
+			ndbinfoGauge(0, "nodeID", "1", "paramName", "Diskless"),
+			ndbinfoGauge(1, "nodeID", "1", "paramName", "StopOnError"),
+			ndbinfoGauge(1, "nodeID", "1", "paramName", "DataDir", "value", "/var/lib/mysql-cluster"),
+			ndbinfoGauge(1, "nodeID", "1", "paramName", "Arbitration", "value", "Default"),
+			ndbinfoGauge(1, "nodeID", "1", "paramName", "LockExecuteThreadToCPU", "value", "1"),
+		},
+	},
+	{
+		name:    "config_values malformed numeric value",
+		scraper: ScrapeNdbinfoConfigValues{},
+		queries: []ndbinfoQueryFixture{{ndbinfoConfigValuesQuery, []string{"node_id", "param_name", "param_type", "config_value"}, [][]driver.Value{
+			{1, "DataMemory", "unsigned", "100M"},
+		}}},
+		wantErr: true,
+	},
+	{
+		name:    "restart_info",
+		scraper: ScrapeNdbinfoRestartInfo{},
+		queries: []ndbinfoQueryFixture{{ndbinfoRestartInfoQuery, ndbinfoRestartInfoColumns, [][]driver.Value{
+			{1, "Restart completed", 15, 0, 0, 1, 0, 0, 0, 0, 2, 1, 0, 30, 10, 0, 15, 4, 20, 60, 1, 115, 1600000000},
+			{2, "Initial state", 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, nil},
+		}}},
+		expected: append(append(
+			ndbinfoRestartInfoMetrics("1", "Restart completed", 15, 115, 0, 0, 1, 0, 0, 0, 0, 2, 1, 0, 30, 10, 0, 15, 4, 20, 60, 1),
+			ndbinfoGauge(1600000000, "nodeID", "1")),
+			ndbinfoRestartInfoMetrics("2", "Initial state", 0, 0, make([]float64, 18)...)...),
+	},
+	{
+		name:    "backup_id",
+		scraper: ScrapeNdbinfoBackupID{},
+		queries: []ndbinfoQueryFixture{{ndbinfoBackupIDQuery, []string{"id"}, [][]driver.Value{
+			{7},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(7),
+		},
+	},
+	{
+		name:    "backup_id before the first backup",
+		scraper: ScrapeNdbinfoBackupID{},
+		queries: []ndbinfoQueryFixture{{ndbinfoBackupIDQuery, []string{"id"}, nil}},
+		expected: []MetricResult{
+			ndbinfoGauge(0),
+		},
+	},
+	{
+		name:    "counters LCP",
+		scraper: ScrapeNdbinfoCountersLCP{},
+		queries: []ndbinfoQueryFixture{{ndbinfoCountersLCPQuery, ndbinfoCountersColumns, [][]driver.Value{
+			{1, "LCP_FRAGMENTS_DONE", 120},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(120, "nodeID", "1", "counterName", "LCP_FRAGMENTS_DONE"),
+		},
+	},
+	{
+		name:    "disk_write_speed_base",
+		scraper: ScrapeNdbinfoDiskWriteSpeedBase{},
+		queries: []ndbinfoQueryFixture{{ndbinfoDiskWriteSpeedBaseQuery, ndbinfoDiskWriteSpeedBaseColumns, [][]driver.Value{
+			{1, 1048576, 524288, 60000, 2000},
+			{2, 0, 524288, 60000, nil},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1048576, "nodeID", "1"),
+			ndbinfoGauge(524288, "nodeID", "1"),
+			ndbinfoGauge(60, "nodeID", "1"),
+			ndbinfoGauge(2, "nodeID", "1"),
+			ndbinfoGauge(0, "nodeID", "2"),
+			ndbinfoGauge(524288, "nodeID", "2"),
+			ndbinfoGauge(60, "nodeID", "2"),
+			ndbinfoGauge(60, "nodeID", "2"),
+		},
+	},
+	{
+		name:    "memory_per_fragment summed per table",
+		scraper: ScrapeNdbinfoMemoryPerFragment{},
+		queries: []ndbinfoQueryFixture{{ndbinfoMemoryPerFragmentQuery, ndbinfoMemoryPerFragColumns, [][]driver.Value{
+			{"test/def/t1", "", "User table", 1, 0, 10, 32768, 1024, 65536, 2048, 8192},
+			{"test/def/t1", "", "User table", 2, 1, 12, 32768, 512, 65536, 0, 8192},
+			{"sys/def/13/PRIMARY", "test/def/t1", "Ordered index", 1, 0, 0, 0, 0, 0, 0, 4096},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(22, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(65536, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(1536, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(131072, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(2048, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(16384, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(0, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+			ndbinfoGauge(0, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+			ndbinfoGauge(0, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+			ndbinfoGauge(0, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+			ndbinfoGauge(0, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+			ndbinfoGauge(4096, "fqName", "sys/def/13/PRIMARY", "parentFqName", "test/def/t1", "type", "Ordered index"),
+		},
+	},
+	{
+		name:    "operations_per_fragment summed per table",
+		scraper: ScrapeNdbinfoOperationsPerFragment{},
+		queries: []ndbinfoQueryFixture{{ndbinfoOperationsPerFragmentQuery, ndbinfoOperationsPerFragColumns, [][]driver.Value{
+			{"test/def/t1", "", "User table", 1, 0, 100, 10, 5, 0, 1, 3, 300, 30, 16, 1},
+			{"test/def/t1", "", "User table", 2, 1, 50, 10, 5, 0, 1, 2, 200, 20, 16, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(150, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(20, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(10, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(0, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(2, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(5, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(500, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(50, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoCounter(32, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+			ndbinfoGauge(1, "fqName", "test/def/t1", "parentFqName", "", "type", "User table"),
+		},
+	},
+	{
+		name:    "counters DBSPJ",
+		scraper: ScrapeNdbinfoCountersSPJ{},
+		queries: []ndbinfoQueryFixture{{ndbinfoCountersSPJQuery, ndbinfoCountersColumns, [][]driver.Value{
+			{1, "READS_RECEIVED", 10},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(10, "nodeID", "1", "counterName", "READS_RECEIVED"),
+		},
+	},
+	{
+		name:    "counters DBTC",
+		scraper: ScrapeNdbinfoCountersTC{},
+		queries: []ndbinfoQueryFixture{{ndbinfoCountersTCQuery, ndbinfoCountersColumns, [][]driver.Value{
+			{1, "TRANSACTIONS", 42},
+			{2, "TRANSACTIONS", 7},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(42, "nodeID", "1", "counterName", "TRANSACTIONS"),
+			ndbinfoGauge(7, "nodeID", "2", "counterName", "TRANSACTIONS"),
+		},
+	},
+	{
+		name:    "counters NULL sum",
+		scraper: ScrapeNdbinfoCountersTC{},
+		queries: []ndbinfoQueryFixture{{ndbinfoCountersTCQuery, ndbinfoCountersColumns, [][]driver.Value{
+			{1, "TRANSACTIONS", nil},
+		}}},
+		wantErr: true,
+	},
+	{
+		name:    "cluster_operations with NULL state",
+		scraper: ScrapeNdbinfoClusterOperations{},
+		queries: []ndbinfoQueryFixture{{ndbinfoClusterOperationsQuery, []string{"node_id", "operation_type", "state", "count(*)"}, [][]driver.Value{
+			{1, "READ", "Prepared", 3},
+			// IFNULL(state,'') in the query turns NULL states into empty strings.
+			{2, "INSERT", "", 1},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(3, "nodeID", "1", "operationType", "READ", "state", "Prepared"),
+			ndbinfoGauge(1, "nodeID", "2", "operationType", "INSERT", "state", ""),
+		},
+	},
+	{
+		name:    "cluster_transactions",
+		scraper: ScrapeNdbinfoClusterTransactions{},
+		queries: []ndbinfoQueryFixture{
+			{ndbinfoClusterTransactionsSQL([]int64{10, 60}, false),
+				[]string{"node_id", "state", "cnt", "MAX(t.inactive_seconds)", "SUM(t.inactive_seconds >= 10)", "SUM(t.inactive_seconds >= 60)"}, [][]driver.Value{
+					{1, "Started", 2, 25, 1, 0},
+					{1, "Committing", 1, 3, 0, 0},
+				}},
+		},
+		expected: []MetricResult{
+			ndbinfoGauge(2, "nodeID", "1", "state", "Started"),
+			ndbinfoGauge(1, "nodeID", "1", "state", "Committing"),
+			ndbinfoGauge(25, "nodeID", "1"),
+			ndbinfoGauge(1, "nodeID", "1", "threshold", "10"),
+			ndbinfoGauge(0, "nodeID", "1", "threshold", "60"),
+		},
+	},
+	{
+		name:    "cluster_locks",
+		scraper: ScrapeNdbinfoClusterLocks{},
+		queries: []ndbinfoQueryFixture{{ndbinfoClusterLocksQuery, ndbinfoClusterLocksColumns, [][]driver.Value{
+			{1, "X", "W", "UPDATE", 2, 15.5, 30, 31, 1, 1, 2, 2, 2, 2},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(2, "nodeID", "1", "mode", "X", "state", "W", "operationType", "UPDATE"),
+			ndbinfoGauge(15.5, "nodeID", "1", "mode", "X", "state", "W", "operationType", "UPDATE"),
+			ndbinfoGauge(0.03, "nodeID", "1", "mode", "X", "state", "W", "operationType", "UPDATE"),
+			ndbinfoHistogram(2, "nodeID", "1", "mode", "X", "state", "W", "operationType", "UPDATE"),
+		},
+	},
+	{
+		name:    "logbuffers NDB 7.5",
+		scraper: ScrapeNdbinfoLogbuffers{},
+		queries: []ndbinfoQueryFixture{{ndbinfoLogbuffersQuery, ndbinfoLogColumns, [][]driver.Value{
+			{1, "REDO", 0, 16777216, 32768},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(32768, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+			ndbinfoGauge(16777216, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+			ndbinfoGauge(0.001953125, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+		},
+	},
+	{
+		// The buffers of backups are reported since NDB 7.6.
+		name:    "logbuffers NDB 7.6",
+		scraper: ScrapeNdbinfoLogbuffers{},
+		queries: []ndbinfoQueryFixture{{ndbinfoLogbuffersQuery, ndbinfoLogColumns, [][]driver.Value{
+			{1, "BACKUP-DATA", 0, 16777216, 4194304},
+			{1, "BACKUP-LOG", 0, 16777216, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(4194304, "nodeID", "1", "logType", "BACKUP-DATA", "logPart", "0"),
+			ndbinfoGauge(16777216, "nodeID", "1", "logType", "BACKUP-DATA", "logPart", "0"),
+			ndbinfoGauge(0.25, "nodeID", "1", "logType", "BACKUP-DATA", "logPart", "0"),
+			ndbinfoGauge(0, "nodeID", "1", "logType", "BACKUP-LOG", "logPart", "0"),
+			ndbinfoGauge(16777216, "nodeID", "1", "logType", "BACKUP-LOG", "logPart", "0"),
+			ndbinfoGauge(0, "nodeID", "1", "logType", "BACKUP-LOG", "logPart", "0"),
+		},
+	},
+	{
+		name:    "logbuffers NDB 8.0",
+		scraper: ScrapeNdbinfoLogbuffers{},
+		queries: []ndbinfoQueryFixture{{ndbinfoLogbuffersQuery, ndbinfoLogColumns, [][]driver.Value{
+			{1, "REDO", 0, 16777216, 32768},
+			{1, "DD-UNDO", 0, 1048576, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(32768, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+			ndbinfoGauge(16777216, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+			ndbinfoGauge(0.001953125, "nodeID", "1", "logType", "REDO", "logPart", "0"),
+			ndbinfoGauge(0, "nodeID", "1", "logType", "DD-UNDO", "logPart", "0"),
+			ndbinfoGauge(1048576, "nodeID", "1", "logType", "DD-UNDO", "logPart", "0"),
+			ndbinfoGauge(0, "nodeID", "1", "logType", "DD-UNDO", "logPart", "0"),
+		},
+	},
+	{
+		name:    "logspaces",
+		scraper: ScrapeNdbinfoLogspaces{},
+		queries: []ndbinfoQueryFixture{{ndbinfoLogspacesQuery, ndbinfoLogColumns, [][]driver.Value{
+			{1, "REDO", 3, 268435456, 1048576},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1048576, "nodeID", "1", "logType", "REDO", "logPart", "3"),
+			ndbinfoGauge(268435456, "nodeID", "1", "logType", "REDO", "logPart", "3"),
+			ndbinfoGauge(0.00390625, "nodeID", "1", "logType", "REDO", "logPart", "3"),
+		},
+	},
+	{
+		name:    "diskpagebuffer",
+		scraper: ScrapeNdbinfoDiskpagebuffers{},
+		queries: []ndbinfoQueryFixture{{ndbinfoDiskpagebuffersQuery, []string{"node_id", "block_instance", "pages_written", "pages_written_lcp", "pages_read",
+			"log_waits", "page_requests_direct_return", "page_requests_wait_queue", "page_requests_wait_io"}, [][]driver.Value{
+			{1, 0, 10, 2, 30, 0, 100, 4, 5},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(10, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(2, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(30, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(0, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(100, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(4, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(5, "nodeID", "1", "threadNO", "0"),
+		},
+	},
+	{
+		name:    "disk_write_speed_aggregate",
+		scraper: ScrapeNdbinfoDiskWriteSpeedAggregate{},
+		queries: []ndbinfoQueryFixture{{ndbinfoDiskWriteSpeedAggregateQuery, []string{"node_id", "thr_no", "backup_lcp_speed_last_10sec", "redo_speed_last_10sec",
+			"slowdowns_due_to_io_lag", "slowdowns_due_to_high_cpu"}, [][]driver.Value{
+			{1, 0, 1048576, 524288, 0, 1},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1048576, "nodeID", "1", "threadNO", "0"),
+			ndbinfoGauge(524288, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(0, "nodeID", "1", "threadNO", "0"),
+			ndbinfoCounter(1, "nodeID", "1", "threadNO", "0"),
+		},
+	},
+	{
+		name:    "resources NDB 7.5",
+		scraper: ScrapeNdbinfoResources{},
+		queries: []ndbinfoQueryFixture{
+			{ndbinfoResourcesQuery, ndbinfoResourcesColumns, [][]driver.Value{
+				{1, "DATA_MEMORY", 64, 32},
+			}},
+			{ndbinfoLongSignalMemoryQuery, ndbinfoLongSignalColumns, [][]driver.Value{
+				{1, 100, 1000},
+			}},
+		},
+		expected: []MetricResult{
+			ndbinfoGauge(64*32768, "nodeID", "1", "memoryType", "DATA_MEMORY"),
+			ndbinfoGauge(32*32768, "nodeID", "1", "memoryType", "DATA_MEMORY"),
+			ndbinfoGauge(1000*256, "nodeID", "1", "memoryType", "LONG_SIGNAL_MEMORY"),
+			ndbinfoGauge(100*256, "nodeID", "1", "memoryType", "LONG_SIGNAL_MEMORY"),
+		},
+	},
+	{
+		// NDB 8.0 accounts transactions and the schema in resources of their own.
+		name:    "resources NDB 8.0",
+		scraper: ScrapeNdbinfoResources{},
+		queries: []ndbinfoQueryFixture{
+			{ndbinfoResourcesQuery, ndbinfoResourcesColumns, [][]driver.Value{
+				{1, "DATA_MEMORY", 64, 32},
+				{1, "TRANSACTION_MEMORY", 10, 20},
+				{1, "SCHEMA_MEMORY", 8, 4},
+			}},
+			{ndbinfoLongSignalMemoryQuery, ndbinfoLongSignalColumns, [][]driver.Value{
+				{1, 100, 1000},
+			}},
+		},
+		expected: []MetricResult{
+			ndbinfoGauge(64*32768, "nodeID", "1", "memoryType", "DATA_MEMORY"),
+			ndbinfoGauge(32*32768, "nodeID", "1", "memoryType", "DATA_MEMORY"),
+			ndbinfoGauge(10*32768, "nodeID", "1", "memoryType", "TRANSACTION_MEMORY"),
+			ndbinfoGauge(20*32768, "nodeID", "1", "memoryType", "TRANSACTION_MEMORY"),
+			ndbinfoGauge(8*32768, "nodeID", "1", "memoryType", "SCHEMA_MEMORY"),
+			ndbinfoGauge(4*32768, "nodeID", "1", "memoryType", "SCHEMA_MEMORY"),
+			ndbinfoGauge(1000*256, "nodeID", "1", "memoryType", "LONG_SIGNAL_MEMORY"),
+			ndbinfoGauge(100*256, "nodeID", "1", "memoryType", "LONG_SIGNAL_MEMORY"),
+		},
+	},
+	{
+		name:    "free resources NDB 8.0",
+		scraper: ScrapeNdbinfoFreeMemory{},
+		queries: []ndbinfoQueryFixture{
+			{ndbinfoFreeMemoryQuery, ndbinfoResourcesColumns, [][]driver.Value{
+				{1, "DATA_MEMORY", 64, 32},
+				// Used can exceed reserved for resources allowed to use shared global memory.
+				{1, "TRANSACTION_MEMORY", 10, 20},
+			}},
+			{ndbinfoLongSignalMemoryQuery, ndbinfoLongSignalColumns, [][]driver.Value{
+				{1, 100, 1000},
+			}},
+		},
+		expected: []MetricResult{
+			ndbinfoGauge(32*32768, "nodeID", "1", "memoryType", "DATA_MEMORY"),
+			ndbinfoGauge(0, "nodeID", "1", "memoryType", "TRANSACTION_MEMORY"),
+			ndbinfoGauge(900*256, "nodeID", "1", "memoryType", "LONG_SIGNAL_MEMORY"),
+		},
+	},
+	{
+		name:    "processes",
+		scraper: ScrapeNdbinfoProcesses{},
+		queries: []ndbinfoQueryFixture{{ndbinfoProcessesQuery, []string{"node_type", "process_name", "count(*)"}, [][]driver.Value{
+			{"NDB", "ndbmtd", 2},
+			{"API", "mysqld", 1},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(2, "nodeType", "NDB", "processName", "ndbmtd"),
+			ndbinfoGauge(1, "nodeType", "API", "processName", "mysqld"),
+		},
+	},
+	{
+		name:    "transporters",
+		scraper: ScrapeNdbinfoTransporters{},
+		queries: []ndbinfoQueryFixture{{ndbinfoTransportersQuery, ndbinfoTransportersColumns, [][]driver.Value{
+			{1, 2, "CONNECTED", "10.0.0.2", 100, 200, 1, 0, 0, 0, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(1, "nodeID", "1", "remoteNodeID", "2", "status", "CONNECTED"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "CONNECTING"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "DISCONNECTED"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "DISCONNECTING"),
+			ndbinfoGauge(1, "nodeID", "1", "remoteNodeID", "2", "remoteAddress", "10.0.0.2"),
+			ndbinfoCounter(100, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(200, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(1, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+		},
+	},
+	{
+		name:    "transporters NULL bytes for a disconnected node",
+		scraper: ScrapeNdbinfoTransporters{},
+		queries: []ndbinfoQueryFixture{{ndbinfoTransportersQuery, ndbinfoTransportersColumns, [][]driver.Value{
+			{1, 2, "DISCONNECTED", "", nil, nil, 0, 0, 0, 0, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "CONNECTED"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "CONNECTING"),
+			ndbinfoGauge(1, "nodeID", "1", "remoteNodeID", "2", "status", "DISCONNECTED"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2", "status", "DISCONNECTING"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+			ndbinfoCounter(0, "nodeID", "1", "remoteNodeID", "2"),
+		},
+	},
+	{
+		name:    "transporter_details",
+		scraper: ScrapeNdbinfoTransporterDetails{},
+		queries: []ndbinfoQueryFixture{{ndbinfoTransporterDetailsQuery, []string{"node_id", "remote_node_id", "trp_id", "type",
+			"bytes_sent", "bytes_received", "sendbuffer_used_bytes", "sendbuffer_max_used_bytes",
+			"sendbuffer_alloc_bytes", "sendbuffer_max_alloc_bytes"}, [][]driver.Value{
+			{1, 2, 3, "TCP", 100, 200, 4096, 65536, 32768, 131072},
+			{1, 3, 4, "TCP", nil, nil, 0, 0, 0, 0},
+		}}},
+		expected: []MetricResult{
+			ndbinfoCounter(100, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoCounter(200, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoGauge(4096, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoGauge(65536, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoGauge(32768, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoGauge(131072, "nodeID", "1", "remoteNodeID", "2", "trpID", "3", "type", "TCP"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "3", "trpID", "4", "type", "TCP"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "3", "trpID", "4", "type", "TCP"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "3", "trpID", "4", "type", "TCP"),
+			ndbinfoGauge(0, "nodeID", "1", "remoteNodeID", "3", "trpID", "4", "type", "TCP"),
+		},
+	},
+	{
+		name:    "pgman_time_track_stats NDB 7.6",
+		scraper: ScrapeNdbinfoPgmanTimeTrack{},
+		queries: []ndbinfoQueryFixture{{ndbinfoPgmanTimeTrackQuery, ndbinfoPgmanTimeTrackColums, [][]driver.Value{
+			{1, 0, 50, 1, 2, 0, 3},
+			{1, 1, 100, 1, 0, 0, 1},
+		}}},
+		expected: []MetricResult{
+			ndbinfoHistogram(2, "nodeID", "1"),
+			ndbinfoHistogram(2, "nodeID", "1"),
+			ndbinfoHistogram(0, "nodeID", "1"),
+			ndbinfoHistogram(4, "nodeID", "1"),
+		},
+	},
+	{
+		name:    "tc_time_track_stats unexpected column count",
+		scraper: ScrapeNdbinfoTcTimeTrack{},
+		queries: []ndbinfoQueryFixture{{ndbinfoTcTimeTrackQuery, []string{"node_id", "upper_bound", "sum(scans)"}, [][]driver.Value{
+			{1, 50, 1},
+		}}},
+		wantErr: true,
+	},
+}
+
+func TestNdbinfoFragmentFilter(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndbinfo.per_fragment.tables", "^test/",
+		"--collect.ndbinfo.per_fragment.tables_exclude", "^test/def/tmp_",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convey.Convey("Tables are selected by fq_name, indexes by their table", t, func() {
+		filter, err := newNdbinfoFragmentFilter()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(filter.match("test/def/t1", ""), convey.ShouldBeTrue)
+		convey.So(filter.match("sys/def/13/PRIMARY", "test/def/t1"), convey.ShouldBeTrue)
+		convey.So(filter.match("mysql/def/ndb_schema", ""), convey.ShouldBeFalse)
+		convey.So(filter.match("test/def/tmp_import", ""), convey.ShouldBeFalse)
+		convey.So(filter.match("sys/def/21/PRIMARY", "test/def/tmp_import"), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Invalid regexp", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.per_fragment.tables", "("})
+		convey.So(err, convey.ShouldBeNil)
+		_, err = newNdbinfoFragmentFilter()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+// ndbinfoRestartInfoMetrics builds the expected restart_info metrics of one node, without the timestamp.
+func ndbinfoRestartInfoMetrics(nodeID, status string, statusInt, total float64, phases ...float64) []MetricResult {
+	labels := []string{"nodeID", nodeID, "nodeRestartStatus", status}
+	metrics := []MetricResult{ndbinfoGauge(statusInt, labels...), ndbinfoGauge(total, labels...)}
+	for i, phase := range ndbinfoRestartPhases {
+		metrics = append(metrics, ndbinfoGauge(phases[i], append(labels, "phase", phase)...))
+	}
+	return metrics
+}
+
+func TestNdbinfoConfigValuesParams(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.config_values.params", "^(DataMemory|RedoBuffer)$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(ndbinfoConfigValuesQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "param_name", "param_type", "config_value"}).
+			AddRow(1, "DataMemory", "unsigned", "104857600").
+			AddRow(1, "DataDir", "string", "/var/lib/mysql-cluster").
+			AddRow(1, "RedoBuffer", "unsigned", "33554432"))
+
+	got, err := collectScraper(ScrapeNdbinfoConfigValues{}, db)
+	convey.Convey("Only matching parameters are collected", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			ndbinfoGauge(104857600, "nodeID", "1", "paramName", "DataMemory"),
+			ndbinfoGauge(33554432, "nodeID", "1", "paramName", "RedoBuffer"),
+		})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
+
+func TestNdbinfoLogFill(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.log_fill.window", "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	labels := []string{"1", "REDO", "0"}
+
+	convey.Convey("Time until full is estimated from the growth rate", t, func() {
+		f := newNdbinfoLogFill()
+		update := func(at time.Duration, used uint64) ndbinfoLogUsage {
+			usage := []ndbinfoLogUsage{{labelValues: labels, used: used, total: 1000}}
+			f.update("logspaces", start.Add(at), usage)
+			return usage[0]
+		}
+
+		convey.So(update(0, 100).estimated, convey.ShouldBeFalse)
+		u := update(10*time.Second, 200)
+		convey.So(u.estimated, convey.ShouldBeTrue)
+		convey.So(u.secondsUntilFull, convey.ShouldAlmostEqual, 80)
+		convey.So(update(20*time.Second, 300).secondsUntilFull, convey.ShouldAlmostEqual, 70)
+
+		// The first sample left the window, usage shrinks over the others.
+		convey.So(update(70*time.Second, 100).secondsUntilFull, convey.ShouldEqual, math.Inf(1))
+		convey.So(update(100*time.Second, 1000).secondsUntilFull, convey.ShouldEqual, 0)
+
+		// The same part of another table has its own samples.
+		usage := []ndbinfoLogUsage{{labelValues: labels, used: 100, total: 1000}}
+		f.update("logbuffers", start.Add(100*time.Second), usage)
+		convey.So(usage[0].estimated, convey.ShouldBeFalse)
+
+		// Parts not seen within the window are forgotten.
+		f.update("logspaces", start.Add(200*time.Second), nil)
+		convey.So(f.samples, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("Samples are kept by the pool", t, func() {
+		pool := NewPool(dsn)
+		convey.So(ndbinfoLogFillFromContext(withPool(context.Background(), pool)), convey.ShouldEqual, pool.logFill)
+		convey.So(ndbinfoLogFillFromContext(context.Background()), convey.ShouldNotEqual, pool.logFill)
+	})
+}
+
+func TestNdbinfoClusterLocksTopWaiters(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.cluster_locks.top_waiters", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(ndbinfoClusterLocksQuery)).
+		WillReturnRows(sqlmock.NewRows(ndbinfoClusterLocksColumns))
+	mock.ExpectQuery(sanitizeQuery(ndbinfoClusterLocksWaitersQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "transid", "mode", "op", "waiter_table", "waiter_duration"}).
+			AddRow(1, 4294967552, "X", "UPDATE", "app/def/accounts", 2500).
+			AddRow(2, 4294967808, "S", "READ", "", 1200).
+			// The same transaction waiting for another row of the table.
+			AddRow(1, 4294967552, "X", "UPDATE", "app/def/accounts", 1800))
+
+	got, err := collectScraper(ScrapeNdbinfoClusterLocks{}, db)
+	convey.Convey("Longest waiting locks", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			ndbinfoGauge(2.5, "nodeID", "1", "transID", "4294967552", "mode", "X", "operationType", "UPDATE", "fqName", "app/def/accounts"),
+			ndbinfoGauge(1.2, "nodeID", "2", "transID", "4294967808", "mode", "S", "operationType", "READ", "fqName", ""),
+		})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
+
+func TestNdbinfoClusterTransactionsThresholds(t *testing.T) {
+	defer kingpin.CommandLine.Parse([]string{})
+
+	convey.Convey("Thresholds are sorted and deduplicated", t, func() {
+		_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.cluster_transactions.inactive_thresholds", "1m, 1s,60s,10s"})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ndbinfoClusterTransactionsThresholds, convey.ShouldResemble, inactiveThresholds{1, 10, 60})
+	})
+
+	convey.Convey("Thresholds must be whole seconds", t, func() {
+		for _, value := range []string{"500ms,1s", "1.5s", "1x"} {
+			_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.cluster_transactions.inactive_thresholds", value})
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+}
+
+func TestNdbinfoClusterTransactionsOldest(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{
+		"--collect.ndbinfo.cluster_transactions.inactive_thresholds", "5m",
+		"--collect.ndbinfo.cluster_transactions.oldest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(ndbinfoClusterTransactionsSQL([]int64{300}, true))).
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "state", "cnt", "MAX(t.inactive_seconds)", "SUM(t.inactive_seconds >= 300)", "oldest"}).
+			AddRow(1, "Started", 1, 200, 0, "4294967808 52 32771 0").
+			AddRow(1, "Prepared", 1, 400, 1, "4294967552 51 32771 12").
+			AddRow(2, "Started", 1, 10, 0, "4294968064 52 32771 0"))
+
+	got, err := collectScraper(ScrapeNdbinfoClusterTransactions{}, db)
+	convey.Convey("Oldest transaction of each node", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			ndbinfoGauge(1, "nodeID", "1", "state", "Started"),
+			ndbinfoGauge(1, "nodeID", "1", "state", "Prepared"),
+			ndbinfoGauge(1, "nodeID", "2", "state", "Started"),
+			ndbinfoGauge(400, "nodeID", "1"),
+			ndbinfoGauge(1, "nodeID", "1", "threshold", "300"),
+			ndbinfoGauge(10, "nodeID", "2"),
+			ndbinfoGauge(0, "nodeID", "2", "threshold", "300"),
+			ndbinfoGauge(1, "nodeID", "1", "transID", "4294967552", "clientNodeID", "51", "clientBlockRef", "32771", "mysqlConnectionID", "12"),
+			ndbinfoGauge(1, "nodeID", "2", "transID", "4294968064", "clientNodeID", "52", "clientBlockRef", "32771", "mysqlConnectionID", "0"),
+		})
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
+
+func TestNdbinfoFragmentStatsByFragment(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{"--collect.ndbinfo.per_fragment.by_fragment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := newNdbinfoFragmentStats()
+	stats.add("test/def/t1", "", "User table", 1, 0, 10)
+	stats.add("test/def/t1", "", "User table", 2, 0, 5)
+	stats.add("test/def/t1", "", "User table", 1, 0, 1)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		stats.collect(ch,
+			[]*prometheus.Desc{newNdbinfoFragmentDesc("memory_per_fragment_rows", "")},
+			[]prometheus.ValueType{prometheus.GaugeValue})
+		close(ch)
+	}()
+
+	var got []MetricResult
+	for m := range ch {
+		got = append(got, readMetric(m))
+	}
+	convey.Convey("Fragment replicas are kept apart", t, func() {
+		convey.So(got, convey.ShouldResemble, []MetricResult{
+			ndbinfoGauge(11, "fqName", "test/def/t1", "parentFqName", "", "type", "User table", "nodeID", "1", "fragmentNum", "0"),
+			ndbinfoGauge(5, "fqName", "test/def/t1", "parentFqName", "", "type", "User table", "nodeID", "2", "fragmentNum", "0"),
+		})
+	})
+}
+
+func TestNdbinfoScrapers(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range ndbinfoFixtures {
+		fixture := fixture
+		t.Run(fixture.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error opening a stub database connection: %s", err)
+			}
+			defer db.Close()
+
+			for _, q := range fixture.queries {
+				rows := sqlmock.NewRows(q.columns)
+				for _, row := range q.rows {
+					rows.AddRow(row...)
+				}
+				mock.ExpectQuery(sanitizeQuery(q.query)).WillReturnRows(rows)
+			}
+
+			ch := make(chan prometheus.Metric)
+			var scrapeErr error
+			go func() {
+				scrapeErr = fixture.scraper.Scrape(context.Background(), db, ch)
+				close(ch)
+			}()
+
+			var got []MetricResult
+			for m := range ch {
+				got = append(got, readMetric(m))
+			}
+
+			convey.Convey(fixture.name, t, func() {
+				if fixture.wantErr {
+					convey.So(scrapeErr, convey.ShouldNotBeNil)
+				} else {
+					convey.So(scrapeErr, convey.ShouldBeNil)
+				}
+				convey.So(got, convey.ShouldResemble, fixture.expected)
+			})
+
+			if fixture.wantErr {
+				return
+			}
+			// Ensure all SQL queries were executed
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled exceptions: %s", err)
+			}
+		})
+	}
+}
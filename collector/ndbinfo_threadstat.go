@@ -105,8 +105,18 @@ func (ScrapeNdbinfoThreadstat) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoThreadstat) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoThreadstat) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoThreadstat) NdbVersion() string {
+	return "7.2.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoThreadstat) NdbinfoTables() []string {
+	return []string{"threadstat"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
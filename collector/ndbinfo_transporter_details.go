@@ -0,0 +1,151 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scrape `ndbinfo.transporter_details`
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Unlike ndbinfo.transporters it has one row per transporter when a pair of
+// nodes is connected by several, the byte counters are NULL while disconnected.
+const ndbinfoTransporterDetailsQuery = `
+	SELECT node_id, remote_node_id, trp_id, type,
+	bytes_sent, bytes_received,
+	sendbuffer_used_bytes, sendbuffer_max_used_bytes,
+	sendbuffer_alloc_bytes, sendbuffer_max_alloc_bytes
+	FROM ndbinfo.transporter_details;
+	`
+
+var ndbinfoTransporterDetailsLabels = []string{"nodeID", "remoteNodeID", "trpID", "type"}
+
+var (
+	ndbinfoTransporterDetailsBytesSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_bytes_sent"),
+		"Number of bytes sent using each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+	ndbinfoTransporterDetailsBytesReceivedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_bytes_received"),
+		"Number of bytes received using each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+	ndbinfoTransporterDetailsSendBufferUsedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_sendbuffer_used_bytes"),
+		"Number of bytes of signal data waiting in the send buffer of each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+	ndbinfoTransporterDetailsSendBufferMaxUsedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_sendbuffer_max_used_bytes"),
+		"Highest number of bytes of signal data waiting in the send buffer of each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+	ndbinfoTransporterDetailsSendBufferAllocDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_sendbuffer_alloc_bytes"),
+		"Number of bytes of send buffer memory allocated by each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+	ndbinfoTransporterDetailsSendBufferMaxAllocDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporter_details_sendbuffer_max_alloc_bytes"),
+		"Highest number of bytes of send buffer memory allocated by each transporter",
+		ndbinfoTransporterDetailsLabels, nil,
+	)
+)
+
+// ScrapeNdbinfoTransporterDetails collects for `ndbinfo.transporter_details`
+type ScrapeNdbinfoTransporterDetails struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeNdbinfoTransporterDetails) Name() string {
+	return "ndbinfo.transporter_details"
+}
+
+// Help describes the role of the Scraper
+func (ScrapeNdbinfoTransporterDetails) Help() string {
+	return "Collect throughput and send buffer usage of each transporter from ndbinfo.transporter_details"
+}
+
+// Version of MySQL from which scraper is available
+func (ScrapeNdbinfoTransporterDetails) Version() MySQLVersion {
+	return MySQLVersion{8, 0}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoTransporterDetails) NdbVersion() string {
+	return "8.0.20"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoTransporterDetails) NdbinfoTables() []string {
+	return []string{"transporter_details"}
+}
+
+// Scrape collects data from database connection and sends it over channel as prometheus metric
+func (ScrapeNdbinfoTransporterDetails) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	ndbinfoTransporterDetailsRows, err := db.QueryContext(ctx, ndbinfoTransporterDetailsQuery)
+	if err != nil {
+		return err
+	}
+	defer ndbinfoTransporterDetailsRows.Close()
+
+	var (
+		nodeID, remoteNodeID, trpID uint64
+		trpType                     string
+		bytesSent, bytesReceived    sql.NullInt64
+		used, maxUsed               uint64
+		alloc, maxAlloc             uint64
+	)
+
+	// Iterate over transporters
+	for ndbinfoTransporterDetailsRows.Next() {
+		if err := ndbinfoTransporterDetailsRows.Scan(
+			&nodeID, &remoteNodeID, &trpID, &trpType,
+			&bytesSent, &bytesReceived,
+			&used, &maxUsed, &alloc, &maxAlloc); err != nil {
+			return err
+		}
+		labelValues := []string{
+			strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10),
+			strconv.FormatUint(trpID, 10), trpType,
+		}
+		if bytesSent.Valid {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransporterDetailsBytesSentDesc, prometheus.CounterValue, float64(bytesSent.Int64),
+				labelValues...)
+		}
+		if bytesReceived.Valid {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransporterDetailsBytesReceivedDesc, prometheus.CounterValue, float64(bytesReceived.Int64),
+				labelValues...)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoTransporterDetailsSendBufferUsedDesc, prometheus.GaugeValue, float64(used),
+			labelValues...)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoTransporterDetailsSendBufferMaxUsedDesc, prometheus.GaugeValue, float64(maxUsed),
+			labelValues...)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoTransporterDetailsSendBufferAllocDesc, prometheus.GaugeValue, float64(alloc),
+			labelValues...)
+		ch <- prometheus.MustNewConstMetric(
+			ndbinfoTransporterDetailsSendBufferMaxAllocDesc, prometheus.GaugeValue, float64(maxAlloc),
+			labelValues...)
+	}
+	return ndbinfoTransporterDetailsRows.Err()
+}
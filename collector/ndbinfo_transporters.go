@@ -23,13 +23,28 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// The byte counters are NULL while a transporter is not connected.
 const ndbinfoTransportersQuery = `
-	SELECT  node_id, remote_node_id, bytes_sent, bytes_received,
+	SELECT  node_id, remote_node_id, status, IFNULL(remote_address, ''),
+	bytes_sent, bytes_received,
 	connect_count, overloaded, overload_count, slowdown, slowdown_count
 	FROM ndbinfo.transporters;
 	`
 
+// ndbinfoTransporterStatuses lists the transporter statuses reported by ndbinfo.transporters.
+var ndbinfoTransporterStatuses = []string{"CONNECTED", "CONNECTING", "DISCONNECTED", "DISCONNECTING"}
+
 var (
+	ndbinfoTransportersStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporters_status"),
+		"Status of each transporter, 1 for the current status and 0 for all others",
+		[]string{"nodeID", "remoteNodeID", "status"}, nil,
+	)
+	ndbinfoTransportersInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("ndb", ndbinfo, "transporters_info"),
+		"Address of the remote node of each transporter, always 1",
+		[]string{"nodeID", "remoteNodeID", "remoteAddress"}, nil,
+	)
 	ndbinfoTransportersBytesSentDesc = prometheus.NewDesc(
 		prometheus.BuildFQName("ndb", ndbinfo, "transporters_bytes_sent"),
 		"Number of bytes sent using this connection",
@@ -81,8 +96,18 @@ func (ScrapeNdbinfoTransporters) Help() string {
 }
 
 // Version of MySQL from which scraper is available
-func (ScrapeNdbinfoTransporters) Version() float64 {
-	return 5.6
+func (ScrapeNdbinfoTransporters) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
+}
+
+// NdbVersion of NDB Cluster from which scraper is available
+func (ScrapeNdbinfoTransporters) NdbVersion() string {
+	return "7.1.0"
+}
+
+// NdbinfoTables the scraper reads from
+func (ScrapeNdbinfoTransporters) NdbinfoTables() []string {
+	return []string{"transporters"}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric
@@ -94,24 +119,52 @@ func (ScrapeNdbinfoTransporters) Scrape(ctx context.Context, db *sql.DB, ch chan
 	defer ndbinfoTransportersRows.Close()
 
 	var (
-		nodeID, remoteNodeID, bytesSent, bytesReceived, connectionCount uint64
-		overloaded, overloadedCount, slowdown, slowdownCount            uint64
+		nodeID, remoteNodeID, connectionCount                uint64
+		overloaded, overloadedCount, slowdown, slowdownCount uint64
+		bytesSent, bytesReceived                             sql.NullInt64
+		status, remoteAddress                                string
 	)
 
 	// Iterate over transporters
 	for ndbinfoTransportersRows.Next() {
 		if err := ndbinfoTransportersRows.Scan(
-			&nodeID, &remoteNodeID, &bytesSent, &bytesReceived,
+			&nodeID, &remoteNodeID, &status, &remoteAddress,
+			&bytesSent, &bytesReceived,
 			&connectionCount, &overloaded, &overloadedCount,
 			&slowdown, &slowdownCount); err != nil {
 			return err
 		}
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTransportersBytesSentDesc, prometheus.CounterValue, float64(bytesSent),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
-		ch <- prometheus.MustNewConstMetric(
-			ndbinfoTransportersBytesReceivedDesc, prometheus.CounterValue, float64(bytesReceived),
-			strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
+		known := false
+		for _, s := range ndbinfoTransporterStatuses {
+			value := 0.0
+			if s == status {
+				value = 1
+				known = true
+			}
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransportersStatusDesc, prometheus.GaugeValue, value,
+				strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10), s)
+		}
+		if !known {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransportersStatusDesc, prometheus.GaugeValue, 1,
+				strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10), status)
+		}
+		if remoteAddress != "" {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransportersInfoDesc, prometheus.GaugeValue, 1,
+				strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10), remoteAddress)
+		}
+		if bytesSent.Valid {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransportersBytesSentDesc, prometheus.CounterValue, float64(bytesSent.Int64),
+				strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
+		}
+		if bytesReceived.Valid {
+			ch <- prometheus.MustNewConstMetric(
+				ndbinfoTransportersBytesReceivedDesc, prometheus.CounterValue, float64(bytesReceived.Int64),
+				strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
+		}
 		ch <- prometheus.MustNewConstMetric(
 			ndbinfoTransportersConnectionCountDesc, prometheus.CounterValue, float64(connectionCount),
 			strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
@@ -128,5 +181,5 @@ func (ScrapeNdbinfoTransporters) Scrape(ctx context.Context, db *sql.DB, ch chan
 			ndbinfoTransportersSlowdownCountDesc, prometheus.CounterValue, float64(slowdownCount),
 			strconv.FormatUint(nodeID, 10), strconv.FormatUint(remoteNodeID, 10))
 	}
-	return nil
+	return ndbinfoTransportersRows.Err()
 }
@@ -162,8 +162,8 @@ func (ScrapePerfEventsStatements) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfEventsStatements) Version() float64 {
-	return 5.6
+func (ScrapePerfEventsStatements) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
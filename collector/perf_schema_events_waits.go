@@ -55,8 +55,8 @@ func (ScrapePerfEventsWaits) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfEventsWaits) Version() float64 {
-	return 5.5
+func (ScrapePerfEventsWaits) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
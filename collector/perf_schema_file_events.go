@@ -64,8 +64,8 @@ func (ScrapePerfFileEvents) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfFileEvents) Version() float64 {
-	return 5.6
+func (ScrapePerfFileEvents) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -74,8 +74,8 @@ func (ScrapePerfFileInstances) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfFileInstances) Version() float64 {
-	return 5.5
+func (ScrapePerfFileInstances) Version() MySQLVersion {
+	return MySQLVersion{5, 5}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
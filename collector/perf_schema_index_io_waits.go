@@ -58,8 +58,8 @@ func (ScrapePerfIndexIOWaits) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfIndexIOWaits) Version() float64 {
-	return 5.6
+func (ScrapePerfIndexIOWaits) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -95,8 +95,13 @@ func (ScrapePerfReplicationApplierStatsByWorker) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfReplicationApplierStatsByWorker) Version() float64 {
-	return 5.7
+func (ScrapePerfReplicationApplierStatsByWorker) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// Applicable unless the server is MariaDB, which lacks the replication tables of performance_schema.
+func (ScrapePerfReplicationApplierStatsByWorker) Applicable(info ServerInfo) bool {
+	return info.Flavor != FlavorMariaDB && info.AtLeast(5, 7)
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -234,3 +239,4 @@ func (ScrapePerfReplicationApplierStatsByWorker) Scrape(ctx context.Context, db
 
 // check interface
 var _ Scraper = ScrapePerfReplicationApplierStatsByWorker{}
+var _ ApplicableScraper = ScrapePerfReplicationApplierStatsByWorker{}
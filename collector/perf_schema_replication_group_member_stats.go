@@ -64,8 +64,13 @@ func (ScrapePerfReplicationGroupMemberStats) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfReplicationGroupMemberStats) Version() float64 {
-	return 5.7
+func (ScrapePerfReplicationGroupMemberStats) Version() MySQLVersion {
+	return MySQLVersion{5, 7}
+}
+
+// Applicable unless the server is MariaDB, which has no group replication.
+func (ScrapePerfReplicationGroupMemberStats) Applicable(info ServerInfo) bool {
+	return info.Flavor != FlavorMariaDB && info.AtLeast(5, 7)
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -111,3 +116,4 @@ func (ScrapePerfReplicationGroupMemberStats) Scrape(ctx context.Context, db *sql
 
 // check interface
 var _ Scraper = ScrapePerfReplicationGroupMemberStats{}
+var _ ApplicableScraper = ScrapePerfReplicationGroupMemberStats{}
@@ -59,8 +59,8 @@ func (ScrapePerfTableIOWaits) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfTableIOWaits) Version() float64 {
-	return 5.6
+func (ScrapePerfTableIOWaits) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -88,8 +88,8 @@ func (ScrapePerfTableLockWaits) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapePerfTableLockWaits) Version() float64 {
-	return 5.6
+func (ScrapePerfTableLockWaits) Version() MySQLVersion {
+	return MySQLVersion{5, 6}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
@@ -0,0 +1,323 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Reconnection backoff bounds.
+const (
+	minReconnectBackoff = 1 * time.Second
+	maxReconnectBackoff = 1 * time.Minute
+)
+
+// Tunable flags.
+var (
+	exporterMaxOpenConns = kingpin.Flag(
+		"exporter.max_open_conns",
+		"Maximum number of open connections to the database.",
+	).Default("1").Int()
+	exporterMaxIdleConns = kingpin.Flag(
+		"exporter.max_idle_conns",
+		"Maximum number of idle connections kept between scrapes.",
+	).Default("1").Int()
+	exporterConnMaxLifetime = kingpin.Flag(
+		"exporter.conn_max_lifetime",
+		"Maximum amount of time a connection may be reused.",
+	).Default("1m").Duration()
+)
+
+// Pool is a long lived MySQL connection pool shared between scrapes.
+// It reopens the connection with exponential backoff when mysqld goes away,
+// and keeps the metrics of cached scrapers for its target.
+type Pool struct {
+	dsn   string
+	cache *scrapeCache
+	// History of the scrapers that compare values between scrapes.
+	nodeRestarts *ndbinfoNodeRestarts
+	logFill      *ndbinfoLogFill
+
+	// Guards the fields below. It is never held while querying mysqld.
+	mu          sync.Mutex
+	db          *sql.DB
+	longRunning *sql.DB
+	connectedAt time.Time
+	info        *ServerInfo
+	infoTime    time.Time
+	ndb         *ndbServer
+	ndbTime     time.Time
+	roles       []string
+	rolesTime   time.Time
+	backoff     time.Duration
+	nextAttempt time.Time
+	reconnects  uint64
+}
+
+// NewPool returns a new Pool for the provided DSN. No connection is made until the first scrape.
+func NewPool(dsn string) *Pool {
+	// Setup extra params for the DSN, default to having a lock timeout.
+	// They are applied as session settings on every connection of the pool.
+	dsnParams := []string{fmt.Sprintf(timeoutParam, *exporterLockTimeout)}
+
+	if *slowLogFilter {
+		dsnParams = append(dsnParams, sessionSettingsParam)
+	}
+
+	if strings.Contains(dsn, "?") {
+		dsn = dsn + "&"
+	} else {
+		dsn = dsn + "?"
+	}
+	dsn += strings.Join(dsnParams, "&")
+
+	return &Pool{
+		dsn:          dsn,
+		cache:        newScrapeCache(),
+		nodeRestarts: newNdbinfoNodeRestarts(),
+		logFill:      newNdbinfoLogFill(),
+	}
+}
+
+// Get returns the shared database handle after verifying mysqld is reachable.
+// While backing off after a failed attempt it returns an error without connecting.
+// A failed ping leaves the handle open: other scrapes may still be using it, and
+// database/sql replaces the broken connections by itself. The ping runs without
+// holding mu, so a slow target doesn't block the other users of the pool.
+func (p *Pool) Get(ctx context.Context) (*sql.DB, error) {
+	p.mu.Lock()
+	if now := time.Now(); now.Before(p.nextAttempt) {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("not reconnecting for another %s", p.nextAttempt.Sub(now).Round(time.Millisecond))
+	}
+	if p.db == nil {
+		db, err := p.open(*exporterMaxOpenConns, *exporterMaxIdleConns)
+		if err != nil {
+			p.fail()
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.db = db
+	}
+	db, attempt := p.db, p.nextAttempt
+	p.mu.Unlock()
+
+	err := db.PingContext(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.db != db {
+		return nil, fmt.Errorf("connection pool closed")
+	}
+	if err != nil {
+		// Don't punish the server for our own scrape being cancelled, nor
+		// back off again for pings that failed along with another one.
+		if ctx.Err() == nil && p.nextAttempt == attempt {
+			p.fail()
+		}
+		return nil, err
+	}
+
+	if p.connectedAt.IsZero() {
+		if p.backoff != 0 {
+			p.reconnects++
+			log.Infoln("Reconnected to mysqld")
+		}
+		p.connectedAt = time.Now()
+		p.backoff = 0
+	}
+	return db, nil
+}
+
+// LongRunning returns the handle of the long running collectors. It is
+// separate from the shared one and limited to a single connection, so they
+// run one after another without taking connections from the other scrapers,
+// whatever exporter.max_open_conns is.
+func (p *Pool) LongRunning() (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.longRunning == nil {
+		db, err := p.open(1, 1)
+		if err != nil {
+			return nil, err
+		}
+		p.longRunning = db
+	}
+	return p.longRunning, nil
+}
+
+// open returns a new handle for the DSN of the pool, without connecting.
+func (p *Pool) open(maxOpenConns, maxIdleConns int) (*sql.DB, error) {
+	db, err := sql.Open("mysql", p.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(*exporterConnMaxLifetime)
+	return db, nil
+}
+
+// ServerInfo returns the flavor and version of the server behind db. It is
+// detected again after exporter.conn_max_lifetime, when the connections it was
+// read from have been replaced, so an upgrade of the server is noticed.
+func (p *Pool) ServerInfo(ctx context.Context, db *sql.DB) (ServerInfo, error) {
+	p.mu.Lock()
+	if p.info != nil && (*exporterConnMaxLifetime <= 0 || time.Since(p.infoTime) < *exporterConnMaxLifetime) {
+		info := *p.info
+		p.mu.Unlock()
+		return info, nil
+	}
+	connectedAt := p.connectedAt
+	p.mu.Unlock()
+
+	info, err := getServerInfo(ctx, db)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connectedAt == connectedAt {
+		p.info, p.infoTime = &info, time.Now()
+	}
+	return info, nil
+}
+
+// NdbServer returns the NDB version and ndbinfo tables of the SQL node behind
+// db, nil if it is not one. Like ServerInfo it is cached for
+// exporter.conn_max_lifetime, an error is not.
+func (p *Pool) NdbServer(ctx context.Context, db *sql.DB, info ServerInfo) (*ndbServer, error) {
+	if info.NdbVersion == nil {
+		return nil, nil
+	}
+	p.mu.Lock()
+	// The version changes with a rolling upgrade of the cluster.
+	if p.ndb != nil && p.ndb.version == *info.NdbVersion &&
+		(*exporterConnMaxLifetime <= 0 || time.Since(p.ndbTime) < *exporterConnMaxLifetime) {
+		ndb := p.ndb
+		p.mu.Unlock()
+		return ndb, nil
+	}
+	connectedAt := p.connectedAt
+	p.mu.Unlock()
+
+	ndb, err := getNdbServer(ctx, db, info)
+	if err != nil {
+		return ndb, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connectedAt == connectedAt {
+		p.ndb, p.ndbTime = ndb, time.Now()
+	}
+	return ndb, nil
+}
+
+// InstanceRoles returns the replication roles of the server behind db. They
+// are cached for a short while as they take several queries to detect.
+func (p *Pool) InstanceRoles(ctx context.Context, db *sql.DB, info ServerInfo) []string {
+	p.mu.Lock()
+	if p.roles != nil && time.Since(p.rolesTime) < instanceRoleCacheInterval {
+		roles := p.roles
+		p.mu.Unlock()
+		return roles
+	}
+	connectedAt := p.connectedAt
+	p.mu.Unlock()
+
+	roles := getInstanceRoles(ctx, db, info)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connectedAt == connectedAt {
+		p.roles, p.rolesTime = roles, time.Now()
+	}
+	return roles
+}
+
+// fail records a failed connection attempt and schedules the next one. Must be called with mu held.
+func (p *Pool) fail() {
+	p.connectedAt = time.Time{}
+	p.info = nil
+	p.ndb = nil
+	p.roles = nil
+	if p.backoff == 0 {
+		p.backoff = minReconnectBackoff
+	} else if p.backoff *= 2; p.backoff > maxReconnectBackoff {
+		p.backoff = maxReconnectBackoff
+	}
+	p.nextAttempt = time.Now().Add(p.backoff)
+}
+
+type poolKey struct{}
+
+// withPool returns a context carrying the pool for the scrapers that keep
+// state about the target between scrapes.
+func withPool(ctx context.Context, p *Pool) context.Context {
+	return context.WithValue(ctx, poolKey{}, p)
+}
+
+// poolFromContext returns the pool being scraped, nil if there is none.
+func poolFromContext(ctx context.Context) *Pool {
+	p, _ := ctx.Value(poolKey{}).(*Pool)
+	return p
+}
+
+// Reconnects returns how many times the pool reconnected after losing mysqld.
+func (p *Pool) Reconnects() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reconnects
+}
+
+// ConnectionAge returns the time since mysqld became reachable, or 0 when it is not.
+func (p *Pool) ConnectionAge() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connectedAt.IsZero() {
+		return 0
+	}
+	return time.Since(p.connectedAt)
+}
+
+// Close closes the underlying database handles.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	if p.longRunning != nil {
+		err = p.longRunning.Close()
+		p.longRunning = nil
+	}
+	if p.db == nil {
+		return err
+	}
+	if closeErr := p.db.Close(); closeErr != nil {
+		err = closeErr
+	}
+	p.db = nil
+	p.connectedAt = time.Time{}
+	p.info = nil
+	p.ndb = nil
+	p.roles = nil
+	return err
+}
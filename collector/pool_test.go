@@ -0,0 +1,156 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestNewPool(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{"--exporter.lock_wait_timeout", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convey.Convey("Session settings are added to the DSN", t, func() {
+		convey.So(NewPool("root@/mysql").dsn, convey.ShouldEqual, "root@/mysql?lock_wait_timeout=5")
+		convey.So(NewPool("root@/mysql?tls=custom").dsn, convey.ShouldEqual, "root@/mysql?tls=custom&lock_wait_timeout=5")
+	})
+}
+
+func TestPoolLongRunning(t *testing.T) {
+	for _, maxOpenConns := range []string{"0", "1", "4"} {
+		_, err := kingpin.CommandLine.Parse([]string{"--exporter.max_open_conns", maxOpenConns})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		convey.Convey("The long running collectors have a connection of their own with max_open_conns="+maxOpenConns, t, func() {
+			pool := NewPool("root@/mysql")
+			db, err := pool.LongRunning()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(db.Stats().MaxOpenConnections, convey.ShouldEqual, 1)
+
+			// The handle is reused until the pool is closed.
+			again, err := pool.LongRunning()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(again, convey.ShouldEqual, db)
+			convey.So(pool.Close(), convey.ShouldBeNil)
+			convey.So(pool.longRunning, convey.ShouldBeNil)
+		})
+	}
+	if _, err := kingpin.CommandLine.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolBackoff(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Grab a free port and close it again so nothing is listening there.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	pool := NewPool(fmt.Sprintf("root@tcp(%s)/", address))
+	defer pool.Close()
+
+	convey.Convey("Unreachable mysqld", t, func() {
+		_, err := pool.Get(context.Background())
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(pool.backoff, convey.ShouldEqual, minReconnectBackoff)
+		// The handle stays open for scrapes still using it.
+		db := pool.db
+		convey.So(db, convey.ShouldNotBeNil)
+
+		// The next attempt is refused until the backoff expires.
+		_, err = pool.Get(context.Background())
+		convey.So(err.Error(), convey.ShouldStartWith, "not reconnecting for another")
+		convey.So(pool.backoff, convey.ShouldEqual, minReconnectBackoff)
+
+		// The retry after the backoff pings the same handle again.
+		pool.nextAttempt = time.Now()
+		_, err = pool.Get(context.Background())
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(pool.db, convey.ShouldEqual, db)
+		convey.So(pool.backoff, convey.ShouldEqual, 2*minReconnectBackoff)
+
+		convey.So(pool.Reconnects(), convey.ShouldEqual, 0)
+		convey.So(pool.ConnectionAge(), convey.ShouldEqual, 0)
+	})
+}
+
+func TestPoolSlowTarget(t *testing.T) {
+	_, err := kingpin.CommandLine.Parse([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A target accepting connections without ever answering the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Kept open until the test ends.
+			defer conn.Close()
+		}
+	}()
+
+	pool := NewPool(fmt.Sprintf("root@tcp(%s)/?readTimeout=300ms", l.Addr()))
+	defer pool.Close()
+
+	convey.Convey("A hanging ping doesn't block the other users of the pool", t, func() {
+		errc := make(chan error)
+		go func() {
+			_, err := pool.Get(context.Background())
+			errc <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			pool.Reconnects()
+			pool.ConnectionAge()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("pool is locked during the ping")
+		}
+
+		convey.So(<-errc, convey.ShouldNotBeNil)
+		convey.So(pool.backoff, convey.ShouldEqual, minReconnectBackoff)
+	})
+}
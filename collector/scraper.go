@@ -30,9 +30,48 @@ type Scraper interface {
 	// Example: "Collect from SHOW ENGINE INNODB STATUS"
 	Help() string
 
-	// Version of MySQL from which scraper is available.
-	Version() float64
+	// Version of MySQL from which scraper is available, compared with the MySQL
+	// version the server is compatible with. Scrapers that depend on more than
+	// that implement ApplicableScraper.
+	Version() MySQLVersion
 
 	// Scrape collects data from database connection and sends it over channel as prometheus metric.
 	Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error
 }
+
+// StandaloneScraper is implemented by scrapers which collect from a source other
+// than the MySQL server, e.g. the NDB management server. They are run even when
+// mysqld cannot be reached and are not subject to the MySQL version check.
+type StandaloneScraper interface {
+	Scraper
+
+	// Standalone reports whether the scraper works without a MySQL connection.
+	Standalone() bool
+}
+
+func isStandalone(scraper Scraper) bool {
+	s, ok := scraper.(StandaloneScraper)
+	return ok && s.Standalone()
+}
+
+// ScraperGroup is implemented by scrapers made of several independent scrapers,
+// which the Exporter runs one by one like any other scraper.
+type ScraperGroup interface {
+	Scraper
+
+	// Scrapers the group currently consists of.
+	Scrapers() []Scraper
+}
+
+// expandScrapers replaces scraper groups by their members.
+func expandScrapers(scrapers []Scraper) []Scraper {
+	expanded := make([]Scraper, 0, len(scrapers))
+	for _, scraper := range scrapers {
+		if group, ok := scraper.(ScraperGroup); ok {
+			expanded = append(expanded, group.Scrapers()...)
+			continue
+		}
+		expanded = append(expanded, scraper)
+	}
+	return expanded
+}
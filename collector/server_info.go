@@ -0,0 +1,222 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables do not fail the query when they are missing, unlike @@ndb_version_string.
+const serverInfoQuery = `
+	SHOW GLOBAL VARIABLES
+	  WHERE Variable_name IN ('version', 'version_comment', 'innodb_version', 'ndb_version_string')
+	`
+
+var (
+	serverVersionRE = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+	mysqlVersionRE  = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+)
+
+// Server flavors.
+const (
+	FlavorMySQL      = "MySQL"
+	FlavorMariaDB    = "MariaDB"
+	FlavorPercona    = "Percona"
+	FlavorNdbCluster = "NDB Cluster"
+)
+
+// Metric descriptors.
+var (
+	serverVersionInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "version", "info"),
+		"MySQL version and distribution.",
+		[]string{"innodb_version", "version", "version_comment", "flavor", "ndb_version"}, nil,
+	)
+)
+
+// ApplicableScraper is implemented by scrapers which decide themselves whether
+// they can run against a server, instead of comparing their Version with the
+// MySQL version of the server.
+type ApplicableScraper interface {
+	Scraper
+
+	// Applicable reports whether the scraper can run against the server.
+	Applicable(info ServerInfo) bool
+}
+
+// MySQLVersion is a MySQL major.minor version, e.g. 5.7 or 8.0.
+type MySQLVersion struct {
+	Major, Minor int
+}
+
+// ParseMySQLVersion parses a "major.minor" version such as "5.7". Each part is
+// read as a number, so "5.10" is newer than "5.9".
+func ParseMySQLVersion(s string) (MySQLVersion, error) {
+	m := mysqlVersionRE.FindStringSubmatch(s)
+	if m == nil {
+		return MySQLVersion{}, fmt.Errorf("invalid MySQL version %q, expected <major>.<minor>", s)
+	}
+	var v MySQLVersion
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	return v, nil
+}
+
+// Less reports whether v is older than o.
+func (v MySQLVersion) Less(o MySQLVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+func (v MySQLVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// ServerInfo describes the flavor and version of the connected server. It is
+// passed to the scrapers in their context, see ServerInfoFromContext.
+type ServerInfo struct {
+	// Flavor is one of FlavorMySQL, FlavorMariaDB, FlavorPercona or FlavorNdbCluster.
+	Flavor string
+	// Major, Minor and Patch version of the server, all 0 if it could not be parsed.
+	Major, Minor, Patch int
+	// NdbVersion of an NDB Cluster SQL node, nil for other servers.
+	NdbVersion *NdbVersion
+
+	// Version, VersionComment and InnodbVersion as reported by the server.
+	Version, VersionComment, InnodbVersion string
+}
+
+// ParseServerInfo derives the flavor and version from the server variables.
+func ParseServerInfo(version, versionComment, innodbVersion, ndbVersion string) ServerInfo {
+	info := ServerInfo{
+		Flavor:         FlavorMySQL,
+		Version:        version,
+		VersionComment: versionComment,
+		InnodbVersion:  innodbVersion,
+	}
+	// MariaDB replication clients may see its version behind a "5.5.5-" prefix.
+	v := version
+	if strings.Contains(v, "MariaDB") {
+		info.Flavor = FlavorMariaDB
+		v = strings.TrimPrefix(v, "5.5.5-")
+	}
+	if m := serverVersionRE.FindStringSubmatch(v); m != nil {
+		info.Major, _ = strconv.Atoi(m[1])
+		info.Minor, _ = strconv.Atoi(m[2])
+		info.Patch, _ = strconv.Atoi(m[3])
+	}
+	if ndbVersion != "" {
+		if ndb, err := ParseNdbVersion(ndbVersion); err == nil {
+			info.NdbVersion = &ndb
+			info.Flavor = FlavorNdbCluster
+		}
+	} else if info.Flavor == FlavorMySQL && strings.Contains(versionComment, "Percona") {
+		info.Flavor = FlavorPercona
+	}
+	return info
+}
+
+// getServerInfo reads the variables describing the server.
+func getServerInfo(ctx context.Context, db *sql.DB) (ServerInfo, error) {
+	rows, err := db.QueryContext(ctx, serverInfoQuery)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	defer rows.Close()
+
+	var name, value string
+	vars := map[string]string{}
+	for rows.Next() {
+		if err := rows.Scan(&name, &value); err != nil {
+			return ServerInfo{}, err
+		}
+		vars[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return ServerInfo{}, err
+	}
+	if vars["version"] == "" {
+		return ServerInfo{}, fmt.Errorf("server did not report its version")
+	}
+	return ParseServerInfo(vars["version"], vars["version_comment"], vars["innodb_version"], vars["ndb_version_string"]), nil
+}
+
+// Known reports whether the version of the server could be parsed.
+func (i ServerInfo) Known() bool {
+	return i.Major != 0
+}
+
+// MySQLVersion returns the version of MySQL the server is compatible with.
+// MariaDB 10.0 and 10.1 map to MySQL 5.6, later versions to 5.7.
+func (i ServerInfo) MySQLVersion() MySQLVersion {
+	if i.Flavor != FlavorMariaDB || i.Major < 10 {
+		return MySQLVersion{i.Major, i.Minor}
+	}
+	if i.Major == 10 && i.Minor < 2 {
+		return MySQLVersion{5, 6}
+	}
+	return MySQLVersion{5, 7}
+}
+
+// AtLeast reports whether the server is compatible with MySQL major.minor. A
+// server of unknown version is assumed to be recent.
+func (i ServerInfo) AtLeast(major, minor int) bool {
+	if !i.Known() {
+		return true
+	}
+	return !i.MySQLVersion().Less(MySQLVersion{major, minor})
+}
+
+// isApplicable reports whether the scraper can run against the server. Without
+// ApplicableScraper the scraper's Version is compared with the MySQL version.
+func isApplicable(scraper Scraper, info ServerInfo) bool {
+	if s, ok := scraper.(ApplicableScraper); ok {
+		return s.Applicable(info)
+	}
+	v := scraper.Version()
+	return info.AtLeast(v.Major, v.Minor)
+}
+
+type serverInfoKey struct{}
+
+// withServerInfo returns a context carrying the server info for the scrapers.
+func withServerInfo(ctx context.Context, info ServerInfo) context.Context {
+	return context.WithValue(ctx, serverInfoKey{}, info)
+}
+
+// ServerInfoFromContext returns the info of the server being scraped, ok is
+// false if it is not known, e.g. for standalone scrapers.
+func ServerInfoFromContext(ctx context.Context) (info ServerInfo, ok bool) {
+	info, ok = ctx.Value(serverInfoKey{}).(ServerInfo)
+	return info, ok
+}
+
+// versionInfoMetric returns the mysql_version_info metric of the server.
+func (i ServerInfo) versionInfoMetric() prometheus.Metric {
+	var ndbVersion string
+	if i.NdbVersion != nil {
+		ndbVersion = i.NdbVersion.String()
+	}
+	return prometheus.MustNewConstMetric(serverVersionInfoDesc, prometheus.GaugeValue, 1,
+		i.InnodbVersion, i.Version, i.VersionComment, i.Flavor, ndbVersion)
+}
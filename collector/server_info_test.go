@@ -0,0 +1,136 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// versionScraper is available from the given MySQL version.
+type versionScraper struct {
+	version MySQLVersion
+}
+
+func (versionScraper) Name() string            { return "test.version" }
+func (versionScraper) Help() string            { return "" }
+func (s versionScraper) Version() MySQLVersion { return s.version }
+
+func (versionScraper) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return nil
+}
+
+func TestParseServerInfo(t *testing.T) {
+	convey.Convey("Flavors and versions", t, func() {
+		info := ParseServerInfo("8.0.21", "MySQL Community Server - GPL", "8.0.21", "")
+		convey.So(info.Flavor, convey.ShouldEqual, FlavorMySQL)
+		convey.So([]int{info.Major, info.Minor, info.Patch}, convey.ShouldResemble, []int{8, 0, 21})
+
+		info = ParseServerInfo("5.5.5-10.3.27-MariaDB-0+deb10u1", "Debian 10", "10.3.27", "")
+		convey.So(info.Flavor, convey.ShouldEqual, FlavorMariaDB)
+		convey.So([]int{info.Major, info.Minor, info.Patch}, convey.ShouldResemble, []int{10, 3, 27})
+
+		info = ParseServerInfo("5.7.31-34-log", "Percona Server (GPL), Release 34", "5.7.31-34", "")
+		convey.So(info.Flavor, convey.ShouldEqual, FlavorPercona)
+		convey.So([]int{info.Major, info.Minor, info.Patch}, convey.ShouldResemble, []int{5, 7, 31})
+
+		info = ParseServerInfo("8.0.22-cluster", "MySQL Cluster Community Server - GPL", "8.0.22", "ndb-8.0.22")
+		convey.So(info.Flavor, convey.ShouldEqual, FlavorNdbCluster)
+		convey.So(info.NdbVersion, convey.ShouldResemble, &NdbVersion{8, 0, 22})
+
+		info = ParseServerInfo("unknown", "", "", "")
+		convey.So(info.Known(), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Versions compare as numbers", t, func() {
+		info := ParseServerInfo("5.10.1", "", "", "")
+		convey.So(info.AtLeast(5, 9), convey.ShouldBeTrue)
+		convey.So(info.AtLeast(8, 0), convey.ShouldBeFalse)
+		convey.So(ParseServerInfo("unknown", "", "", "").AtLeast(8, 0), convey.ShouldBeTrue)
+
+		v, err := ParseMySQLVersion("5.10")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v, convey.ShouldResemble, MySQLVersion{5, 10})
+		convey.So(MySQLVersion{5, 9}.Less(v), convey.ShouldBeTrue)
+		convey.So(isApplicable(versionScraper{v}, ParseServerInfo("5.9.1", "", "", "")), convey.ShouldBeFalse)
+		convey.So(isApplicable(versionScraper{v}, info), convey.ShouldBeTrue)
+
+		_, err = ParseMySQLVersion("5.7.1")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("MariaDB is compared by its MySQL compatible version", t, func() {
+		mariadb := ParseServerInfo("10.3.27-MariaDB", "", "", "")
+		mysql57 := ParseServerInfo("5.7.31", "", "", "")
+		mysql80 := ParseServerInfo("8.0.21", "", "", "")
+
+		convey.So(isApplicable(ScrapeGlobalStatus{}, mariadb), convey.ShouldBeTrue)
+		convey.So(isApplicable(ScrapeNdbinfoBackupID{}, mariadb), convey.ShouldBeFalse)
+		convey.So(isApplicable(ScrapeNdbinfoBackupID{}, mysql80), convey.ShouldBeTrue)
+		convey.So(isApplicable(ScrapePerfReplicationGroupMemberStats{}, mariadb), convey.ShouldBeFalse)
+		convey.So(isApplicable(ScrapePerfReplicationGroupMemberStats{}, mysql57), convey.ShouldBeTrue)
+		convey.So(isApplicable(ScrapeInfoSchemaInnodbTablespaces{}, mariadb), convey.ShouldBeTrue)
+		convey.So(isApplicable(ScrapeInfoSchemaInnodbTablespaces{}, mysql57), convey.ShouldBeTrue)
+		convey.So(isApplicable(ScrapeInfoSchemaInnodbTablespaces{}, mysql80), convey.ShouldBeFalse)
+	})
+}
+
+func TestGetServerInfoQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"Variable_name", "Value"}
+	mock.ExpectQuery(sanitizeQuery(serverInfoQuery)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("innodb_version", "5.7.28").
+		AddRow("ndb_version_string", "ndb-7.6.12").
+		AddRow("version", "5.7.28-ndb-7.6.12-cluster-gpl").
+		AddRow("version_comment", "MySQL Cluster Community Server (GPL)"))
+
+	convey.Convey("NDB Cluster SQL node", t, func() {
+		info, err := getServerInfo(context.Background(), db)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(info.Flavor, convey.ShouldEqual, FlavorNdbCluster)
+		convey.So(info.NdbVersion, convey.ShouldResemble, &NdbVersion{7, 6, 12})
+
+		got := readMetric(info.versionInfoMetric())
+		convey.So(got.labels, convey.ShouldResemble, labelMap{
+			"innodb_version":  "5.7.28",
+			"version":         "5.7.28-ndb-7.6.12-cluster-gpl",
+			"version_comment": "MySQL Cluster Community Server (GPL)",
+			"flavor":          "NDB Cluster",
+			"ndb_version":     "7.6.12",
+		})
+	})
+
+	convey.Convey("Server info is passed in the context", t, func() {
+		_, ok := ServerInfoFromContext(context.Background())
+		convey.So(ok, convey.ShouldBeFalse)
+		info, ok := ServerInfoFromContext(withServerInfo(context.Background(), ParseServerInfo("8.0.21", "", "", "")))
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(info.Major, convey.ShouldEqual, 8)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled exceptions: %s", err)
+	}
+}
@@ -56,8 +56,8 @@ func (ScrapeSlaveHosts) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeSlaveHosts) Version() float64 {
-	return 5.1
+func (ScrapeSlaveHosts) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
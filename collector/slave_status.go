@@ -63,8 +63,8 @@ func (ScrapeSlaveStatus) Help() string {
 }
 
 // Version of MySQL from which scraper is available.
-func (ScrapeSlaveStatus) Version() float64 {
-	return 5.1
+func (ScrapeSlaveStatus) Version() MySQLVersion {
+	return MySQLVersion{5, 1}
 }
 
 // Scrape collects data from database connection and sends it over channel as prometheus metric.
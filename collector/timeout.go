@@ -0,0 +1,55 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Tunable flags.
+var (
+	scraperDefaultTimeout = kingpin.Flag(
+		"collect.timeout",
+		"Timeout of each collector, 0 to only limit them by the scrape timeout.",
+	).Default("0s").Duration()
+	scraperTimeouts = scraperDurations{}
+)
+
+func init() {
+	kingpin.Flag(
+		"collect.scraper_timeout",
+		"Timeout of a collector as <collector>=<duration>, e.g. ndbinfo.cluster_operations=2s, overriding collect.timeout. Can be repeated.",
+	).PlaceHolder("COLLECTOR=DURATION").SetValue(scraperTimeouts)
+}
+
+// Metric descriptors.
+var (
+	scraperSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "collector_success"),
+		"Whether the last run of a collector succeeded (1 for success, 0 for error or timeout).",
+		[]string{"collector"}, nil,
+	)
+)
+
+// scraperTimeout returns the timeout of the scraper, the collect.scraper_timeout
+// flag takes precedence over collect.timeout.
+func scraperTimeout(scraper Scraper) time.Duration {
+	if timeout, ok := scraperTimeouts[scraper.Name()]; ok {
+		return timeout
+	}
+	return *scraperDefaultTimeout
+}
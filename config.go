@@ -0,0 +1,505 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/mysqld_exporter/collector"
+)
+
+// Config is the content of the file passed with --config.file.
+type Config struct {
+	// Collectors enables or disables scrapers by name, overriding their collect.* flags.
+	Collectors map[string]bool `yaml:"collectors"`
+	// Options set collect.* flags and the exporter label flags by name, e.g.
+	// collect.heartbeat.database. Repeatable flags take a list or a map.
+	Options map[string]interface{} `yaml:"options"`
+	// Client holds the credentials of the exporter's own mysqld, like [client] in .my.cnf.
+	Client *AuthModule `yaml:"client"`
+	// AuthModules holds named credentials for /probe, like [client.<name>] in .my.cnf.
+	AuthModules map[string]AuthModule `yaml:"auth_modules"`
+}
+
+// AuthModule holds credentials and connection settings for mysqld.
+type AuthModule struct {
+	User     string     `yaml:"user"`
+	Password string     `yaml:"password"`
+	Host     string     `yaml:"host"`
+	Port     int        `yaml:"port"`
+	Socket   string     `yaml:"socket"`
+	TLS      *TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig configures TLS for the connection to mysqld.
+type TLSConfig struct {
+	CA                 string `yaml:"ca"`
+	Cert               string `yaml:"cert"`
+	Key                string `yaml:"key"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// loadConfig reads and validates the config file.
+func loadConfig(filename string, options *flagOptions) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %s", filename, err)
+	}
+	if err := cfg.validate(options); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", filename, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate(options *flagOptions) error {
+	for name := range c.Collectors {
+		if findScraper(name) == nil {
+			return fmt.Errorf("collectors: unknown collector %q", name)
+		}
+	}
+	for name, value := range c.Options {
+		if err := options.check(name, value); err != nil {
+			return fmt.Errorf("options: %s", err)
+		}
+	}
+	if c.Client != nil {
+		if err := c.Client.validate(); err != nil {
+			return fmt.Errorf("client: %s", err)
+		}
+	}
+	for name, module := range c.AuthModules {
+		if name == "client" {
+			return fmt.Errorf("auth_modules: %q is reserved for the client section", name)
+		}
+		if err := module.validate(); err != nil {
+			return fmt.Errorf("auth_modules: %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func (m *AuthModule) validate() error {
+	if m.User == "" || m.Password == "" {
+		return fmt.Errorf("user and password are required")
+	}
+	if m.TLS != nil && m.TLS.CA == "" && !m.TLS.InsecureSkipVerify {
+		return fmt.Errorf("tls: ca is required unless insecure_skip_verify is set")
+	}
+	if m.TLS != nil && (m.TLS.Cert == "") != (m.TLS.Key == "") {
+		return fmt.Errorf("tls: cert and key must be set together")
+	}
+	return nil
+}
+
+// findScraper returns the scraper with the given name, with or without collect. prefix.
+func findScraper(name string) collector.Scraper {
+	name = strings.TrimPrefix(name, "collect.")
+	for scraper := range scrapers {
+		if scraper.Name() == name {
+			return scraper
+		}
+	}
+	return nil
+}
+
+// authModule returns the credentials of the client section or the named auth module.
+func (c *Config) authModule(name string) (*AuthModule, bool) {
+	if name == "client" {
+		return c.Client, c.Client != nil
+	}
+	m, ok := c.AuthModules[name]
+	return &m, ok
+}
+
+// loadTLS loads the TLS config of the client section and of all auth modules
+// into tlsCfgs, so building their DSNs for each probe doesn't read the files again.
+func (c *Config) loadTLS(tlsCfgs tlsConfigs) error {
+	if c.Client != nil {
+		if err := c.Client.loadTLS("client", tlsCfgs); err != nil {
+			return fmt.Errorf("client: %s", err)
+		}
+	}
+	for name, module := range c.AuthModules {
+		if err := module.loadTLS(name, tlsCfgs); err != nil {
+			return fmt.Errorf("auth_modules: %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func (m *AuthModule) loadTLS(name string, tlsCfgs tlsConfigs) error {
+	if m.TLS == nil {
+		return nil
+	}
+	if err := tlsCfgs.load("config-"+name, m.TLS.CA, m.TLS.Cert, m.TLS.Key, m.TLS.InsecureSkipVerify); err != nil {
+		return fmt.Errorf("failed to register a custom TLS configuration for mysql dsn: %s", err)
+	}
+	return nil
+}
+
+// dsn builds a DSN from the auth module, its TLS config must have been
+// registered. If target is not empty it overrides the host, port and socket
+// of the module.
+func (m *AuthModule) dsn(name, target string) string {
+	var dsn string
+	if target != "" {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, "3306")
+		}
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/", m.User, m.Password, target)
+	} else if m.Socket != "" {
+		dsn = fmt.Sprintf("%s:%s@unix(%s)/", m.User, m.Password, m.Socket)
+	} else {
+		host, port := m.Host, m.Port
+		if host == "" {
+			host = "localhost"
+		}
+		if port == 0 {
+			port = 3306
+		}
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/", m.User, m.Password, net.JoinHostPort(host, fmt.Sprint(port)))
+	}
+	if m.TLS != nil {
+		dsn = fmt.Sprintf("%s?tls=config-%s", dsn, name)
+	}
+	return dsn
+}
+
+// exporterOptions are the flags besides collect.* that can be set from the
+// config file, they are read on every scrape.
+var exporterOptions = map[string]bool{
+	"exporter.const_label":   true,
+	"exporter.role_label":    true,
+	"exporter.instance_role": true,
+}
+
+// flagOptions sets collect.* flags from the config file. Flags not set by the
+// current config file keep the value they got from the command line.
+// Collector ON/OFF flags are left alone, they are overridden in the collectors section.
+type flagOptions struct {
+	flags    map[string]*kingpin.FlagModel
+	baseline map[string][]string
+}
+
+// resettableValue is a repeatable flag that can be set again from the config file.
+type resettableValue interface {
+	kingpin.Value
+	// Values returns the values as passed on the command line.
+	Values() []string
+	// Reset removes all values.
+	Reset()
+}
+
+// newFlagOptions must be called after the command line was parsed.
+func newFlagOptions(app *kingpin.Application) *flagOptions {
+	o := &flagOptions{
+		flags:    map[string]*kingpin.FlagModel{},
+		baseline: map[string][]string{},
+	}
+	for _, flag := range app.Model().Flags {
+		if !(strings.HasPrefix(flag.Name, "collect.") || exporterOptions[flag.Name]) || findScraper(flag.Name) != nil {
+			continue
+		}
+		if !isCumulative(flag) {
+			o.baseline[flag.Name] = []string{flag.Value.String()}
+		} else if value, ok := flag.Value.(resettableValue); ok {
+			o.baseline[flag.Name] = value.Values()
+		}
+		o.flags[flag.Name] = flag
+	}
+	return o
+}
+
+// check returns an error if the flag can't be set to value from the config file.
+func (o *flagOptions) check(name string, value interface{}) error {
+	if findScraper(name) != nil {
+		return fmt.Errorf("%q enables a collector, use the collectors section", name)
+	}
+	flag, ok := o.flags[name]
+	if !ok {
+		return fmt.Errorf("unknown option %q", name)
+	}
+	if _, ok := o.baseline[name]; !ok {
+		return fmt.Errorf("option %q can only be set on the command line", name)
+	}
+	if values := optionValues(value); len(values) != 1 && !isCumulative(flag) {
+		return fmt.Errorf("option %q takes a single value", name)
+	}
+	return nil
+}
+
+// apply resets all flags to their command line values and sets the options.
+// The values of a repeatable flag in the config file replace those of the command line.
+func (o *flagOptions) apply(options map[string]interface{}) error {
+	for name, values := range o.baseline {
+		if err := o.set(name, values); err != nil {
+			return fmt.Errorf("failed restoring %s: %s", name, err)
+		}
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := o.check(name, options[name]); err != nil {
+			return err
+		}
+		if err := o.set(name, optionValues(options[name])); err != nil {
+			return fmt.Errorf("option %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// set sets the flag to values, replacing the values of a repeatable flag.
+func (o *flagOptions) set(name string, values []string) error {
+	if value, ok := o.flags[name].Value.(resettableValue); ok && isCumulative(o.flags[name]) {
+		value.Reset()
+	}
+	for _, value := range values {
+		if err := o.flags[name].Value.Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionValues returns the values of an option as they would be passed on the
+// command line. A list is a repeated flag, and so is a map of <name>=<value>.
+func optionValues(value interface{}) []string {
+	switch value := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return values
+	case map[interface{}]interface{}:
+		values := make([]string, 0, len(value))
+		for k, v := range value {
+			values = append(values, fmt.Sprintf("%v=%v", k, v))
+		}
+		sort.Strings(values)
+		return values
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+// isCumulative reports whether the flag can be repeated.
+func isCumulative(flag *kingpin.FlagModel) bool {
+	cumulative, ok := flag.Value.(interface{ IsCumulative() bool })
+	return ok && cumulative.IsCumulative()
+}
+
+// exporterState is what the HTTP handlers serve from. Handlers hold the read
+// lock while scraping, so a reload never changes options under a running scrape.
+type exporterState struct {
+	mu         sync.RWMutex
+	scrapers   []collector.Scraper
+	pool       *collector.Pool
+	probePools *probePools
+}
+
+// reloader (re)builds the exporterState from the command line and the config file.
+type reloader struct {
+	configFile   string
+	options      *flagOptions
+	scraperFlags map[collector.Scraper]*bool
+	state        *exporterState
+
+	// Serializes reloads and guards the fields below.
+	mu     sync.Mutex
+	config *Config
+	dsn    string
+}
+
+// reload reads the config file and applies it, keeping the running state on errors.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg := &Config{}
+	if r.configFile != "" {
+		var err error
+		if cfg, err = loadConfig(r.configFile, r.options); err != nil {
+			return err
+		}
+	}
+
+	dsn, resolver, tlsCfgs, err := credentials(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	if err := r.options.apply(cfg.Options); err != nil {
+		r.restoreOptions()
+		return err
+	}
+	// The options may point collect.custom_query.file elsewhere.
+	if err := collector.LoadCustomQueries(); err != nil {
+		r.restoreOptions()
+		return err
+	}
+
+	overrides := map[collector.Scraper]bool{}
+	for name, enabled := range cfg.Collectors {
+		overrides[findScraper(name)] = enabled
+	}
+	log.Infof("Enabled scrapers:")
+	var enabledScrapers []collector.Scraper
+	for scraper, flag := range r.scraperFlags {
+		enabled, ok := overrides[scraper]
+		if !ok {
+			enabled = *flag
+		}
+		if enabled {
+			log.Infof(" --collect.%s", scraper.Name())
+			enabledScrapers = append(enabledScrapers, scraper)
+		}
+	}
+	r.state.scrapers = enabledScrapers
+
+	// The config is accepted, the DSNs below may now use its TLS configs.
+	tlsCfgs.register()
+
+	// Keep the pool, its connection and cached metrics, unless the credentials changed.
+	if r.state.pool == nil || dsn != r.dsn {
+		if r.state.pool != nil {
+			r.state.pool.Close()
+		}
+		r.state.pool = collector.NewPool(dsn)
+	}
+	if r.state.probePools == nil {
+		r.state.probePools = newProbePools(resolver)
+	} else {
+		r.state.probePools.setResolver(resolver)
+	}
+
+	r.config = cfg
+	r.dsn = dsn
+	return nil
+}
+
+// restoreOptions sets the options of the running config again after a failed reload.
+func (r *reloader) restoreOptions() {
+	var options map[string]interface{}
+	if r.config != nil {
+		options = r.config.Options
+	}
+	r.options.apply(options)
+}
+
+// credentials returns the exporter's DSN, the resolver of /probe DSNs and the TLS
+// configs they use, which the caller registers. The config file takes precedence
+// over DATA_SOURCE_NAME, which takes precedence over .my.cnf.
+func credentials(cfg *Config) (string, probeDSNFunc, tlsConfigs, error) {
+	// The .my.cnf also provides the credentials of the /probe auth modules.
+	mycnf, mycnfErr := loadMycnf(*configMycnf)
+
+	tlsCfgs := tlsConfigs{}
+	if err := cfg.loadTLS(tlsCfgs); err != nil {
+		return "", nil, nil, err
+	}
+
+	var resolver probeDSNFunc
+	switch {
+	case cfg.Client != nil || len(cfg.AuthModules) > 0:
+		var fallback probeDSNFunc
+		if mycnfErr == nil {
+			fallback = mycnfProbeDSN(mycnf, *configMycnf, tlsCfgs)
+		}
+		resolver = func(target, authModule string) (string, error) {
+			m, ok := cfg.authModule(authModule)
+			if ok {
+				return m.dsn(authModule, target), nil
+			}
+			// Modules missing from the config file, e.g. client, are read from the .my.cnf.
+			if fallback != nil {
+				return fallback(target, authModule)
+			}
+			return "", fmt.Errorf("unknown auth_module %q", authModule)
+		}
+	case mycnfErr == nil:
+		resolver = mycnfProbeDSN(mycnf, *configMycnf, tlsCfgs)
+	default:
+		resolver = func(target, authModule string) (string, error) {
+			return "", fmt.Errorf("no credentials available, %s could not be read", *configMycnf)
+		}
+	}
+
+	if cfg.Client != nil {
+		return cfg.Client.dsn("client", ""), resolver, tlsCfgs, nil
+	}
+	if dsn := os.Getenv("DATA_SOURCE_NAME"); dsn != "" {
+		return dsn, resolver, tlsCfgs, nil
+	}
+	if mycnfErr != nil {
+		return "", nil, nil, mycnfErr
+	}
+	dsn, err := mycnfDSN(mycnf, *configMycnf, "client", "", tlsCfgs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return dsn, resolver, tlsCfgs, nil
+}
+
+// mycnfProbeDSN resolves /probe DSNs from the [client] and [client.<name>] sections of a .my.cnf.
+// The TLS configs of the sections are loaded into tlsCfgs once, an error is returned when probing with the section.
+func mycnfProbeDSN(mycnf *ini.File, config interface{}, tlsCfgs tlsConfigs) probeDSNFunc {
+	tlsNames := map[string]string{}
+	tlsErrs := map[string]error{}
+	for _, section := range mycnf.SectionStrings() {
+		tlsNames[section], tlsErrs[section] = mycnfTLS(mycnf, section, tlsCfgs)
+	}
+	return func(target, authModule string) (string, error) {
+		section := mycnfSection(authModule)
+		if _, err := mycnf.GetSection(section); err != nil {
+			return "", fmt.Errorf("unknown auth_module %q", authModule)
+		}
+		if err := tlsErrs[section]; err != nil {
+			return "", err
+		}
+		return mycnfSectionDSN(mycnf, config, section, target, tlsNames[section])
+	}
+}
+
+// mycnfSection returns the .my.cnf section of an auth module. Auth modules are
+// named the same in the .my.cnf and the config file: client is the [client]
+// section, any other <name> is the [client.<name>] section.
+func mycnfSection(authModule string) string {
+	if authModule == "client" {
+		return authModule
+	}
+	return "client." + authModule
+}
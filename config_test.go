@@ -0,0 +1,320 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/smartystreets/goconvey/convey"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/prometheus/mysqld_exporter/collector"
+)
+
+func writeConfig(t *testing.T, dir, content string) string {
+	filename := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadConfig(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+	options := newFlagOptions(kingpin.CommandLine)
+
+	dir, err := ioutil.TempDir("", "mysqld_exporter-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	convey.Convey("Valid config", t, func() {
+		cfg, err := loadConfig(writeConfig(t, dir, `
+collectors:
+  info_schema.processlist: true
+  collect.global_variables: false
+options:
+  collect.heartbeat.database: monitoring
+  collect.info_schema.processlist.min_time: 5
+  collect.min_interval: [info_schema.tables=5m]
+  exporter.const_label: {cluster: prod}
+client:
+  user: exporter
+  password: secret
+  host: db1
+  port: 3307
+auth_modules:
+  cluster1:
+    user: probe
+    password: secret
+`), options)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(cfg.Collectors, convey.ShouldResemble, map[string]bool{"info_schema.processlist": true, "collect.global_variables": false})
+
+		convey.So(cfg.Client.dsn("client", ""), convey.ShouldEqual, "exporter:secret@tcp(db1:3307)/")
+
+		m, ok := cfg.authModule("cluster1")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(m.dsn("cluster1", "10.0.0.1"), convey.ShouldEqual, "probe:secret@tcp(10.0.0.1:3306)/")
+
+		_, ok = cfg.authModule("cluster2")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	errors := []struct {
+		name, config, err string
+	}{
+		{"unknown field", "colectors: {}", "field colectors not found"},
+		{"unknown collector", "collectors: {info_schema.nope: true}", `collectors: unknown collector "info_schema.nope"`},
+		{"unknown option", "options: {collect.nope: 1}", `options: unknown option "collect.nope"`},
+		{"non collector option", "options: {web.listen-address: ':9105'}", `options: unknown option "web.listen-address"`},
+		{"collector as option", "options: {collect.global_status: false}", "use the collectors section"},
+		{"list for a single value", "options: {collect.heartbeat.database: [a, b]}", `option "collect.heartbeat.database" takes a single value`},
+		{"non reloadable exporter option", "options: {exporter.max_open_conns: 2}", `options: unknown option "exporter.max_open_conns"`},
+		{"missing password", "client: {user: exporter}", "client: user and password are required"},
+		{"tls without ca", "client: {user: a, password: b, tls: {cert: c.pem, key: c.key}}", "client: tls: ca is required"},
+		{"reserved auth module", "auth_modules: {client: {user: a, password: b}}", "is reserved"},
+	}
+	convey.Convey("Invalid configs", t, func() {
+		for _, e := range errors {
+			_, err := loadConfig(writeConfig(t, dir, e.config), options)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, e.err)
+		}
+	})
+}
+
+func TestFlagOptions(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{"--collect.heartbeat.table", "beats", "--collect.min_interval", "global_status=1m"}); err != nil {
+		t.Fatal(err)
+	}
+	defer kingpin.CommandLine.Parse([]string{})
+	options := newFlagOptions(kingpin.CommandLine)
+	// Repeated flags keep their values across parsing.
+	defer options.flags["collect.min_interval"].Value.(resettableValue).Reset()
+	value := func(name string) string {
+		return options.flags[name].Value.String()
+	}
+
+	convey.Convey("Options override the command line until removed", t, func() {
+		err := options.apply(map[string]interface{}{
+			"collect.heartbeat.database":               "monitoring",
+			"collect.heartbeat.table":                  "pulse",
+			"collect.info_schema.processlist.min_time": 5,
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(value("collect.heartbeat.database"), convey.ShouldEqual, "monitoring")
+		convey.So(value("collect.heartbeat.table"), convey.ShouldEqual, "pulse")
+		convey.So(value("collect.info_schema.processlist.min_time"), convey.ShouldEqual, "5")
+
+		convey.So(options.apply(nil), convey.ShouldBeNil)
+		convey.So(value("collect.heartbeat.database"), convey.ShouldEqual, "heartbeat")
+		convey.So(value("collect.heartbeat.table"), convey.ShouldEqual, "beats")
+		convey.So(value("collect.info_schema.processlist.min_time"), convey.ShouldEqual, "0")
+	})
+
+	convey.Convey("Repeatable options replace the command line until removed", t, func() {
+		err := options.apply(map[string]interface{}{
+			"collect.min_interval": map[interface{}]interface{}{"info_schema.tables": "5m"},
+			"exporter.const_label": []interface{}{"dc=eu", "cluster=prod"},
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(value("collect.min_interval"), convey.ShouldEqual, "info_schema.tables=5m0s")
+		convey.So(value("exporter.const_label"), convey.ShouldEqual, "cluster=prod,dc=eu")
+
+		convey.So(options.apply(nil), convey.ShouldBeNil)
+		convey.So(value("collect.min_interval"), convey.ShouldEqual, "global_status=1m0s")
+		convey.So(value("exporter.const_label"), convey.ShouldEqual, "")
+	})
+
+	convey.Convey("Invalid values", t, func() {
+		err := options.apply(map[string]interface{}{"collect.info_schema.processlist.min_time": "soon"})
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldStartWith, "option collect.info_schema.processlist.min_time:")
+	})
+}
+
+func TestReload(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+	defer kingpin.CommandLine.Parse([]string{})
+
+	dir, err := ioutil.TempDir("", "mysqld_exporter-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	on, off := true, false
+	state := &exporterState{}
+	r := &reloader{
+		configFile: writeConfig(t, dir, `
+collectors:
+  info_schema.processlist: true
+client:
+  user: exporter
+  password: secret
+`),
+		options: newFlagOptions(kingpin.CommandLine),
+		scraperFlags: map[collector.Scraper]*bool{
+			collector.ScrapeGlobalStatus{}: &on,
+			collector.ScrapeProcesslist{}:  &off,
+		},
+		state: state,
+	}
+	reloadCh := make(chan chan error)
+	go func() {
+		for errc := range reloadCh {
+			errc <- r.reload()
+		}
+	}()
+	defer close(reloadCh)
+	handler := newReloadHandler(reloadCh, true)
+
+	convey.Convey("Config reloads", t, func() {
+		convey.So(r.reload(), convey.ShouldBeNil)
+		convey.So(state.scrapers, convey.ShouldHaveLength, 2)
+		pool := state.pool
+
+		// Same credentials keep the pool.
+		writeConfig(t, dir, `
+collectors:
+  global_status: false
+client:
+  user: exporter
+  password: secret
+`)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/-/reload", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+		convey.So(state.scrapers, convey.ShouldBeEmpty)
+		convey.So(state.pool, convey.ShouldEqual, pool)
+
+		// An invalid config keeps the running state.
+		writeConfig(t, dir, "collectors: {nope: true}")
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/-/reload", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusInternalServerError)
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, `unknown collector "nope"`)
+		convey.So(state.pool, convey.ShouldEqual, pool)
+
+		// New credentials get a new pool.
+		writeConfig(t, dir, `
+client:
+  user: exporter
+  password: changed
+`)
+		convey.So(r.reload(), convey.ShouldBeNil)
+		convey.So(state.pool, convey.ShouldNotEqual, pool)
+		convey.So(state.scrapers, convey.ShouldResemble, []collector.Scraper{collector.ScrapeGlobalStatus{}})
+
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/-/reload", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+	})
+
+	convey.Convey("TLS configs are registered once the reload is accepted", t, func() {
+		ca := newTestCert(t, dir, "ca", nil, x509.ExtKeyUsageServerAuth)
+		registered := func() bool {
+			_, err := mysql.ParseDSN("probe:secret@tcp(db1:3306)/?tls=config-secure")
+			return err == nil
+		}
+
+		writeConfig(t, dir, fmt.Sprintf(`
+client:
+  user: exporter
+  password: secret
+auth_modules:
+  secure:
+    user: probe
+    password: secret
+    tls: {ca: %s}
+  broken:
+    user: probe
+    password: secret
+    tls: {ca: %s}
+`, ca.certFile, filepath.Join(dir, "missing.crt")))
+		convey.So(r.reload(), convey.ShouldNotBeNil)
+		convey.So(registered(), convey.ShouldBeFalse)
+
+		writeConfig(t, dir, fmt.Sprintf(`
+client:
+  user: exporter
+  password: secret
+auth_modules:
+  secure:
+    user: probe
+    password: secret
+    tls: {ca: %s}
+`, ca.certFile))
+		convey.So(r.reload(), convey.ShouldBeNil)
+		convey.So(registered(), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("Reloading via HTTP must be enabled", t, func() {
+		w := httptest.NewRecorder()
+		newReloadHandler(reloadCh, false)(w, httptest.NewRequest("POST", "/-/reload", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqld_exporter-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mycnf := filepath.Join(dir, ".my.cnf")
+	if err := ioutil.WriteFile(mycnf, []byte("[client]\nuser = root\npassword = abc123\n\n[client.cluster2]\npassword = other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kingpin.CommandLine.Parse([]string{"--config.my-cnf", mycnf}); err != nil {
+		t.Fatal(err)
+	}
+	defer kingpin.CommandLine.Parse([]string{})
+	defer os.Setenv("DATA_SOURCE_NAME", os.Getenv("DATA_SOURCE_NAME"))
+	os.Unsetenv("DATA_SOURCE_NAME")
+
+	convey.Convey("Auth modules missing from the config file are read from the .my.cnf", t, func() {
+		cfg := &Config{AuthModules: map[string]AuthModule{"cluster1": {User: "probe", Password: "secret"}}}
+		dsn, resolver, _, err := credentials(cfg)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(localhost:3306)/")
+
+		dsn, err = resolver("10.0.0.1", "cluster1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(dsn, convey.ShouldEqual, "probe:secret@tcp(10.0.0.1:3306)/")
+		dsn, err = resolver("10.0.0.1", "client")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(10.0.0.1:3306)/")
+		dsn, err = resolver("10.0.0.1", "cluster2")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(dsn, convey.ShouldEqual, "root:other@tcp(10.0.0.1:3306)/")
+		_, err = resolver("10.0.0.1", "cluster3")
+		convey.So(err, convey.ShouldBeError, `unknown auth_module "cluster3"`)
+	})
+}
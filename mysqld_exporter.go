@@ -19,10 +19,14 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -41,6 +45,10 @@ var (
 		"web.listen-address",
 		"Address to listen on for web interface and telemetry.",
 	).Default(":9104").String()
+	webConfigFile = kingpin.Flag(
+		"web.config.file",
+		"Path to a YAML file with TLS and basic auth settings of the web server, reloaded when it changes.",
+	).Default("").String()
 	metricPath = kingpin.Flag(
 		"web.telemetry-path",
 		"Path under which to expose metrics.",
@@ -51,9 +59,24 @@ var (
 	).Default("0.25").Float64()
 	configMycnf = kingpin.Flag(
 		"config.my-cnf",
-		"Path to .my.cnf file to read MySQL credentials from.",
+		"Path to .my.cnf file to read MySQL credentials from. /probe?auth_module=<name> reads the [client.<name>] section.",
 	).Default(path.Join(os.Getenv("HOME"), ".my.cnf")).String()
-	dsn string
+	configFile = kingpin.Flag(
+		"config.file",
+		"Path to a YAML file with collectors, their options and credentials, reloaded on SIGHUP or, with --web.enable-lifecycle, POST /-/reload. /probe?auth_module=<name> reads auth_modules.<name>.",
+	).Default("").String()
+	webEnableLifecycle = kingpin.Flag(
+		"web.enable-lifecycle",
+		"Enable reloading the config file via HTTP POST to /-/reload.",
+	).Default("false").Bool()
+	probeMaxPools = kingpin.Flag(
+		"probe.max-pools",
+		"Maximum number of /probe targets whose connections are kept, the least recently probed are closed first. 0 for no limit.",
+	).Default("100").Int()
+	probePoolIdleTimeout = kingpin.Flag(
+		"probe.pool-idle-timeout",
+		"Close the connections of /probe targets not probed for this long. 0 to keep them.",
+	).Default("10m").Duration()
 )
 
 // scrapers lists all possible collection methods and if they should be enabled by default.
@@ -90,6 +113,12 @@ var scrapers = map[collector.Scraper]bool{
 	collector.ScrapeSlaveHosts{}:                          false,
 	collector.ScrapeNdbinfoMemoryusage{}:                  true,
 	collector.ScrapeNdbinfoThreadstat{}:                   true,
+	collector.ScrapeNdbinfoCpustat{}:                      true,
+	collector.ScrapeNdbinfoNodes{}:                        true,
+	collector.ScrapeNdbinfoConfigValues{}:                 false,
+	collector.ScrapeNdbinfoRestartInfo{}:                  true,
+	collector.ScrapeNdbinfoMemoryPerFragment{}:            false,
+	collector.ScrapeNdbinfoOperationsPerFragment{}:        false,
 	collector.ScrapeNdbinfoCountersSPJ{}:                  true,
 	collector.ScrapeNdbinfoCountersTC{}:                   true,
 	collector.ScrapeNdbinfoClusterOperations{}:            true,
@@ -99,64 +128,128 @@ var scrapers = map[collector.Scraper]bool{
 	collector.ScrapeNdbinfoLogspaces{}:                    true,
 	collector.ScrapeNdbinfoDiskpagebuffers{}:              true,
 	collector.ScrapeNdbinfoDiskWriteSpeedAggregate{}:      true,
+	collector.ScrapeNdbinfoDiskWriteSpeedBase{}:           true,
+	collector.ScrapeNdbinfoCountersLCP{}:                  true,
+	collector.ScrapeNdbinfoBackupID{}:                     true,
 	collector.ScrapeNdbinfoResources{}:                    true,
 	collector.ScrapeNdbinfoFreeMemory{}:                   true,
 	collector.ScrapeNdbinfoProcesses{}:                    true,
 	collector.ScrapeNdbinfoTransporters{}:                 true,
+	collector.ScrapeNdbinfoTransporterDetails{}:           true,
 	collector.ScrapeNdbinfoPgmanTimeTrack{}:               true,
 	collector.ScrapeNdbinfoTcTimeTrack{}:                  true,
+	collector.ScrapeNdbMgmStatus{}:                        false,
+	collector.ScrapeNdbMgmConfig{}:                        false,
+	collector.ScrapeNdbMgmEvents{}:                        false,
+	collector.ScrapeCustomQueries{}:                       false,
 	collector.ScrapeFiles{}:                               true,
 }
 
 func parseMycnf(config interface{}) (string, error) {
-	var dsn string
+	cfg, err := loadMycnf(config)
+	if err != nil {
+		return "", err
+	}
+	tlsCfgs := tlsConfigs{}
+	dsn, err := mycnfDSN(cfg, config, "client", "", tlsCfgs)
+	if err != nil {
+		return "", err
+	}
+	tlsCfgs.register()
+	return dsn, nil
+}
+
+// loadMycnf reads a .my.cnf file, or its contents when passed as []byte.
+func loadMycnf(config interface{}) (*ini.File, error) {
 	opts := ini.LoadOptions{
 		// MySQL ini file can have boolean keys.
 		AllowBooleanKeys: true,
 	}
 	cfg, err := ini.LoadSources(opts, config)
 	if err != nil {
-		return dsn, fmt.Errorf("failed reading ini file: %s", err)
+		return nil, fmt.Errorf("failed reading ini file: %s", err)
+	}
+	return cfg, nil
+}
+
+// mycnfDSN builds a DSN from the credentials in the given section of a parsed .my.cnf,
+// adding its TLS config to tlsCfgs. If target is not empty it overrides the host,
+// port and socket of the section.
+func mycnfDSN(cfg *ini.File, config interface{}, section, target string, tlsCfgs tlsConfigs) (string, error) {
+	tlsName, err := mycnfTLS(cfg, section, tlsCfgs)
+	if err != nil {
+		return "", err
+	}
+	return mycnfSectionDSN(cfg, config, section, target, tlsName)
+}
+
+// mycnfTLS loads the TLS config of a .my.cnf section into tlsCfgs and returns
+// its name, empty if the section has no ssl-ca.
+func mycnfTLS(cfg *ini.File, section string, tlsCfgs tlsConfigs) (string, error) {
+	sslCA := cfg.Section(section).Key("ssl-ca").String()
+	sslCert := cfg.Section(section).Key("ssl-cert").String()
+	sslKey := cfg.Section(section).Key("ssl-key").String()
+	if sslCA == "" {
+		return "", nil
+	}
+	// Every section gets its own TLS config, [client] keeps the historic "custom" name.
+	tlsName := "custom"
+	if section != "client" {
+		tlsName = "custom-" + section
 	}
-	user := cfg.Section("client").Key("user").String()
-	password := cfg.Section("client").Key("password").String()
+	if err := tlsCfgs.load(tlsName, sslCA, sslCert, sslKey, false); err != nil {
+		return "", fmt.Errorf("failed to register a custom TLS configuration for mysql dsn: %s", err)
+	}
+	return tlsName, nil
+}
+
+// mycnfSectionDSN builds the DSN of a .my.cnf section using the registered TLS config tlsName.
+func mycnfSectionDSN(cfg *ini.File, config interface{}, section, target, tlsName string) (string, error) {
+	var dsn string
+	user := cfg.Section(section).Key("user").String()
+	password := cfg.Section(section).Key("password").String()
 	if (user == "") || (password == "") {
-		return dsn, fmt.Errorf("no user or password specified under [client] in %s", config)
+		return dsn, fmt.Errorf("no user or password specified under [%s] in %s", section, config)
 	}
-	host := cfg.Section("client").Key("host").MustString("localhost")
-	port := cfg.Section("client").Key("port").MustUint(3306)
-	socket := cfg.Section("client").Key("socket").String()
-	if socket != "" {
+	host := cfg.Section(section).Key("host").MustString("localhost")
+	port := cfg.Section(section).Key("port").MustUint(3306)
+	socket := cfg.Section(section).Key("socket").String()
+	if target != "" {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, "3306")
+		}
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/", user, password, target)
+	} else if socket != "" {
 		dsn = fmt.Sprintf("%s:%s@unix(%s)/", user, password, socket)
 	} else {
 		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/", user, password, host, port)
 	}
-	sslCA := cfg.Section("client").Key("ssl-ca").String()
-	sslCert := cfg.Section("client").Key("ssl-cert").String()
-	sslKey := cfg.Section("client").Key("ssl-key").String()
-	if sslCA != "" {
-		if tlsErr := customizeTLS(sslCA, sslCert, sslKey); tlsErr != nil {
-			tlsErr = fmt.Errorf("failed to register a custom TLS configuration for mysql dsn: %s", tlsErr)
-			return dsn, tlsErr
-		}
-		dsn = fmt.Sprintf("%s?tls=custom", dsn)
+	if tlsName != "" {
+		dsn = fmt.Sprintf("%s?tls=%s", dsn, tlsName)
 	}
 
 	log.Debugln(dsn)
 	return dsn, nil
 }
 
-func customizeTLS(sslCA string, sslCert string, sslKey string) error {
-	var tlsCfg tls.Config
+// tlsConfigs are TLS configs loaded for the MySQL driver by name. They are
+// registered with the driver only once the credentials using them are accepted,
+// so a failed reload doesn't replace the TLS configs of the running connections.
+type tlsConfigs map[string]*tls.Config
+
+func (t tlsConfigs) load(name string, sslCA string, sslCert string, sslKey string, insecureSkipVerify bool) error {
+	tlsCfg := tls.Config{InsecureSkipVerify: insecureSkipVerify}
 	caBundle := x509.NewCertPool()
-	pemCA, err := ioutil.ReadFile(sslCA)
-	if err != nil {
-		return err
-	}
-	if ok := caBundle.AppendCertsFromPEM(pemCA); ok {
-		tlsCfg.RootCAs = caBundle
-	} else {
-		return fmt.Errorf("failed parse pem-encoded CA certificates from %s", sslCA)
+	if sslCA != "" {
+		pemCA, err := ioutil.ReadFile(sslCA)
+		if err != nil {
+			return err
+		}
+		if ok := caBundle.AppendCertsFromPEM(pemCA); ok {
+			tlsCfg.RootCAs = caBundle
+		} else {
+			return fmt.Errorf("failed parse pem-encoded CA certificates from %s", sslCA)
+		}
 	}
 	if sslCert != "" && sslKey != "" {
 		certPairs := make([]tls.Certificate, 0, 1)
@@ -168,64 +261,86 @@ func customizeTLS(sslCA string, sslCert string, sslKey string) error {
 		certPairs = append(certPairs, keypair)
 		tlsCfg.Certificates = certPairs
 	}
-	mysql.RegisterTLSConfig("custom", &tlsCfg)
+	t[name] = &tlsCfg
 	return nil
 }
 
+// register registers the loaded TLS configs with the MySQL driver.
+func (t tlsConfigs) register() {
+	for name, tlsCfg := range t {
+		mysql.RegisterTLSConfig(name, tlsCfg)
+	}
+}
+
 func init() {
 	prometheus.MustRegister(version.NewCollector("mysqld_exporter"))
 }
 
-func newHandler(metrics collector.Metrics, scrapers []collector.Scraper) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		filteredScrapers := scrapers
-		params := r.URL.Query()["collect[]"]
-		// Use request context for cancellation when connection gets closed.
-		ctx := r.Context()
-		// If a timeout is configured via the Prometheus header, add it to the context.
-		if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
-			timeoutSeconds, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				log.Errorf("Failed to parse timeout from Prometheus header: %s", err)
+// scrapeContext returns the request context, limited by the timeout from the
+// X-Prometheus-Scrape-Timeout-Seconds header if present.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	// Use request context for cancellation when connection gets closed.
+	ctx := r.Context()
+	// If a timeout is configured via the Prometheus header, add it to the context.
+	if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+		timeoutSeconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Errorf("Failed to parse timeout from Prometheus header: %s", err)
+		} else {
+			if *timeoutOffset >= timeoutSeconds {
+				// Ignore timeout offset if it doesn't leave time to scrape.
+				log.Errorf(
+					"Timeout offset (--timeout-offset=%.2f) should be lower than prometheus scrape time (X-Prometheus-Scrape-Timeout-Seconds=%.2f).",
+					*timeoutOffset,
+					timeoutSeconds,
+				)
 			} else {
-				if *timeoutOffset >= timeoutSeconds {
-					// Ignore timeout offset if it doesn't leave time to scrape.
-					log.Errorf(
-						"Timeout offset (--timeout-offset=%.2f) should be lower than prometheus scrape time (X-Prometheus-Scrape-Timeout-Seconds=%.2f).",
-						*timeoutOffset,
-						timeoutSeconds,
-					)
-				} else {
-					// Subtract timeout offset from timeout.
-					timeoutSeconds -= *timeoutOffset
-				}
-				// Create new timeout context with request context as parent.
-				var cancel context.CancelFunc
-				ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSeconds*float64(time.Second)))
-				defer cancel()
-				// Overwrite request with timeout context.
-				r = r.WithContext(ctx)
+				// Subtract timeout offset from timeout.
+				timeoutSeconds -= *timeoutOffset
 			}
+			// Create new timeout context with request context as parent.
+			return context.WithTimeout(ctx, time.Duration(timeoutSeconds*float64(time.Second)))
 		}
-		log.Debugln("collect query:", params)
+	}
+	return ctx, func() {}
+}
 
-		// Check if we have some "collect[]" query parameters.
-		if len(params) > 0 {
-			filters := make(map[string]bool)
-			for _, param := range params {
-				filters[param] = true
-			}
+// filterScrapers returns the scrapers selected by the "collect[]" query parameters,
+// or all of them when there are none.
+func filterScrapers(r *http.Request, scrapers []collector.Scraper) []collector.Scraper {
+	params := r.URL.Query()["collect[]"]
+	log.Debugln("collect query:", params)
 
-			filteredScrapers = nil
-			for _, scraper := range scrapers {
-				if filters[scraper.Name()] {
-					filteredScrapers = append(filteredScrapers, scraper)
-				}
-			}
+	// Check if we have some "collect[]" query parameters.
+	if len(params) == 0 {
+		return scrapers
+	}
+	filters := make(map[string]bool)
+	for _, param := range params {
+		filters[param] = true
+	}
+
+	var filteredScrapers []collector.Scraper
+	for _, scraper := range scrapers {
+		if filters[scraper.Name()] {
+			filteredScrapers = append(filteredScrapers, scraper)
 		}
+	}
+	return filteredScrapers
+}
+
+func newHandler(metrics collector.Metrics, state *exporterState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+		// Overwrite request with timeout context.
+		r = r.WithContext(ctx)
+
+		state.mu.RLock()
+		defer state.mu.RUnlock()
 
 		registry := prometheus.NewRegistry()
-		registry.MustRegister(collector.New(ctx, dsn, metrics, filteredScrapers))
+		registry.MustRegister(collector.New(ctx, state.pool, metrics, filterScrapers(r, state.scrapers)))
 
 		gatherers := prometheus.Gatherers{
 			prometheus.DefaultGatherer,
@@ -237,6 +352,176 @@ func newHandler(metrics collector.Metrics, scrapers []collector.Scraper) http.Ha
 	}
 }
 
+// probeDSNFunc returns the DSN for target using the credentials of authModule.
+type probeDSNFunc func(target, authModule string) (string, error)
+
+// probePools keeps one connection pool per probed target and auth module.
+// Pools not probed for a while, or beyond maxPools, are closed.
+type probePools struct {
+	mu          sync.Mutex
+	resolver    probeDSNFunc
+	pools       map[string]*probePool
+	maxPools    int
+	idleTimeout time.Duration
+}
+
+// probePool is a pool with the probe it was created for.
+type probePool struct {
+	pool       *collector.Pool
+	target     string
+	authModule string
+	// Probes using the pool, it is not closed while they run.
+	users    int
+	lastUsed time.Time
+}
+
+func newProbePools(resolver probeDSNFunc) *probePools {
+	return &probePools{
+		resolver:    resolver,
+		pools:       map[string]*probePool{},
+		maxPools:    *probeMaxPools,
+		idleTimeout: *probePoolIdleTimeout,
+	}
+}
+
+// setResolver replaces the credentials after a reload. Pools of unchanged
+// DSNs are kept, the others are closed.
+func (p *probePools) setResolver(resolver probeDSNFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolver = resolver
+
+	for dsn, entry := range p.pools {
+		if newDSN, err := resolver(entry.target, entry.authModule); err != nil || newDSN != dsn {
+			p.close(dsn)
+		}
+	}
+}
+
+// get returns the pool for target using the credentials of the authModule
+// section, and a function to call once the probe is done with it.
+func (p *probePools) get(target, authModule string) (*collector.Pool, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dsn, err := p.resolver(target, authModule)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, ok := p.pools[dsn]
+	if !ok {
+		entry = &probePool{pool: collector.NewPool(dsn), target: target, authModule: authModule}
+		p.pools[dsn] = entry
+	}
+	entry.users++
+	entry.lastUsed = time.Now()
+	p.evict()
+
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		entry.users--
+		entry.lastUsed = time.Now()
+	}
+	return entry.pool, release, nil
+}
+
+// expire closes the pools that have been idle for longer than idleTimeout.
+func (p *probePools) expire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evict()
+}
+
+// evict closes the idle pools that expired, then the least recently used
+// ones beyond maxPools. Pools in use are kept. Must be called with mu held.
+func (p *probePools) evict() {
+	now := time.Now()
+	for dsn, entry := range p.pools {
+		if entry.users == 0 && p.idleTimeout > 0 && now.Sub(entry.lastUsed) > p.idleTimeout {
+			p.close(dsn)
+		}
+	}
+	for p.maxPools > 0 && len(p.pools) > p.maxPools {
+		var oldest string
+		for dsn, entry := range p.pools {
+			if entry.users == 0 && (oldest == "" || entry.lastUsed.Before(p.pools[oldest].lastUsed)) {
+				oldest = dsn
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		p.close(oldest)
+	}
+}
+
+// close closes the pool of dsn and forgets it. Must be called with mu held.
+func (p *probePools) close(dsn string) {
+	if err := p.pools[dsn].pool.Close(); err != nil {
+		log.Errorf("Error closing the connections of %s: %s", p.pools[dsn].target, err)
+	}
+	delete(p.pools, dsn)
+}
+
+func newProbeHandler(state *exporterState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		authModule := r.URL.Query().Get("auth_module")
+		if authModule == "" {
+			authModule = "client"
+		}
+
+		state.mu.RLock()
+		defer state.mu.RUnlock()
+
+		pool, release, err := state.probePools.get(target, authModule)
+		if err != nil {
+			log.Errorf("Error probing %s: %s", target, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer release()
+
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+		// Overwrite request with timeout context.
+		r = r.WithContext(ctx)
+
+		// Exporter metrics are scoped to this probe, like blackbox_exporter does.
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector.New(ctx, pool, collector.NewMetrics(), filterScrapers(r, state.scrapers)))
+
+		// Delegate http serving to Prometheus client library, which will call collector.Collect.
+		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+		h.ServeHTTP(w, r)
+	}
+}
+
+// newReloadHandler triggers a reload of the config file on POST, if enabled.
+func newReloadHandler(reload chan<- chan error, enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			http.Error(w, "Lifecycle API is not enabled.", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "This endpoint requires a POST request.", http.StatusMethodNotAllowed)
+			return
+		}
+		errc := make(chan error)
+		reload <- errc
+		if err := <-errc; err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+		}
+	}
+}
+
 func main() {
 	// Generate ON/OFF flags for all scrapers.
 	scraperFlags := map[collector.Scraper]*bool{}
@@ -274,29 +559,64 @@ func main() {
 	log.Infoln("Starting mysqld_exporter", version.Info())
 	log.Infoln("Build context", version.BuildContext())
 
-	dsn = os.Getenv("DATA_SOURCE_NAME")
-	if len(dsn) == 0 {
-		var err error
-		if dsn, err = parseMycnf(*configMycnf); err != nil {
-			log.Fatal(err)
-		}
+	state := &exporterState{}
+	reloader := &reloader{
+		configFile:   *configFile,
+		options:      newFlagOptions(kingpin.CommandLine),
+		scraperFlags: scraperFlags,
+		state:        state,
+	}
+	if err := reloader.reload(); err != nil {
+		log.Fatal(err)
 	}
 
-	// Register only scrapers enabled by flag.
-	log.Infof("Enabled scrapers:")
-	enabledScrapers := []collector.Scraper{}
-	for scraper, enabled := range scraperFlags {
-		if *enabled {
-			log.Infof(" --collect.%s", scraper.Name())
-			enabledScrapers = append(enabledScrapers, scraper)
+	// Reload on SIGHUP or POST /-/reload if enabled, the HTTP server keeps running.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloadCh := make(chan chan error)
+	go func() {
+		for {
+			var errc chan error
+			select {
+			case <-hup:
+			case errc = <-reloadCh:
+			}
+			err := reloader.reload()
+			if err != nil {
+				log.Errorln("Error reloading config:", err)
+			} else {
+				log.Infoln("Reloaded config")
+			}
+			if errc != nil {
+				errc <- err
+			}
 		}
-	}
-	handlerFunc := newHandler(collector.NewMetrics(), enabledScrapers)
+	}()
+
+	// Close the connections of targets that are no longer probed.
+	go func() {
+		for range time.Tick(time.Minute) {
+			state.probePools.expire()
+		}
+	}()
+
+	handlerFunc := newHandler(collector.NewMetrics(), state)
 	http.Handle(*metricPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handlerFunc))
+	http.HandleFunc("/probe", newProbeHandler(state))
+	http.HandleFunc("/-/reload", newReloadHandler(reloadCh, *webEnableLifecycle))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write(landingPage)
 	})
 
+	web, err := newWebServer(*webConfigFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.Infoln("Listening on", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	server := &http.Server{Handler: web.handler(http.DefaultServeMux)}
+	log.Fatal(web.serve(server, listener))
 }
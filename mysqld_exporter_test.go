@@ -19,11 +19,13 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"testing"
@@ -134,6 +136,159 @@ func TestParseMycnf(t *testing.T) {
 	})
 }
 
+func TestMycnfDSN(t *testing.T) {
+	const config = `
+		[client]
+		user = root
+		password = abc123
+
+		[client.cluster1]
+		user = exporter
+		password = secret
+		socket = /var/lib/mysql/mysql.sock
+	`
+	convey.Convey("Named .my.cnf sections", t, func() {
+		cfg, err := loadMycnf([]byte(config))
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Target overrides host and port", func() {
+			dsn, err := mycnfDSN(cfg, config, "client", "10.0.0.1:3307", tlsConfigs{})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(10.0.0.1:3307)/")
+		})
+		convey.Convey("Target without port", func() {
+			dsn, err := mycnfDSN(cfg, config, "client", "10.0.0.1", tlsConfigs{})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(10.0.0.1:3306)/")
+		})
+		convey.Convey("Target overrides socket of named section", func() {
+			dsn, err := mycnfDSN(cfg, config, "client.cluster1", "10.0.0.2:3306", tlsConfigs{})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "exporter:secret@tcp(10.0.0.2:3306)/")
+		})
+		convey.Convey("Section without credentials", func() {
+			_, err := mycnfDSN(cfg, config, "other", "10.0.0.2:3306", tlsConfigs{})
+			convey.So(err, convey.ShouldBeError, fmt.Errorf("no user or password specified under [other] in %s", config))
+		})
+		convey.Convey("Auth modules are read from the client.<name> sections", func() {
+			resolver := mycnfProbeDSN(cfg, config, tlsConfigs{})
+			dsn, err := resolver("10.0.0.2:3306", "cluster1")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "exporter:secret@tcp(10.0.0.2:3306)/")
+			_, err = resolver("10.0.0.2:3306", "client.cluster1")
+			convey.So(err, convey.ShouldBeError, `unknown auth_module "client.cluster1"`)
+		})
+		convey.Convey("TLS errors are reported for their section only", func() {
+			cfg, err := loadMycnf([]byte(config + "\n[client.tls]\nuser = a\npassword = b\nssl-ca = /nonexistent/ca.pem\n"))
+			convey.So(err, convey.ShouldBeNil)
+			resolver := mycnfProbeDSN(cfg, config, tlsConfigs{})
+			_, err = resolver("10.0.0.2:3306", "tls")
+			convey.So(err, convey.ShouldNotBeNil)
+			dsn, err := resolver("10.0.0.2:3306", "client")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(10.0.0.2:3306)/")
+		})
+	})
+}
+
+func TestProbeHandler(t *testing.T) {
+	const config = `
+		[client]
+		user = root
+		password = abc123
+	`
+	cfg, err := loadMycnf([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newProbeHandler(&exporterState{probePools: newProbePools(mycnfProbeDSN(cfg, config, tlsConfigs{}))})
+
+	convey.Convey("Invalid probe requests", t, func() {
+		convey.Convey("Missing target", func() {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/probe", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+		})
+		convey.Convey("Unknown auth module", func() {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/probe?target=10.0.0.1:3306&auth_module=client.nope", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(w.Body.String(), convey.ShouldContainSubstring, "unknown auth_module")
+		})
+	})
+
+	convey.Convey("Pools are reused per target", t, func() {
+		pools := newProbePools(mycnfProbeDSN(cfg, config, tlsConfigs{}))
+		a, release, err := pools.get("10.0.0.1:3306", "client")
+		convey.So(err, convey.ShouldBeNil)
+		release()
+		b, release, err := pools.get("10.0.0.1:3306", "client")
+		convey.So(err, convey.ShouldBeNil)
+		release()
+		c, release, err := pools.get("10.0.0.2:3306", "client")
+		convey.So(err, convey.ShouldBeNil)
+		release()
+		convey.So(a, convey.ShouldEqual, b)
+		convey.So(a, convey.ShouldNotEqual, c)
+	})
+
+	convey.Convey("Pools are closed", t, func() {
+		pools := newProbePools(mycnfProbeDSN(cfg, config, tlsConfigs{}))
+		probe := func(target string) func() {
+			_, release, err := pools.get(target, "client")
+			convey.So(err, convey.ShouldBeNil)
+			return release
+		}
+		targets := func() []string {
+			var targets []string
+			for _, entry := range pools.pools {
+				targets = append(targets, entry.target)
+			}
+			sort.Strings(targets)
+			return targets
+		}
+
+		convey.Convey("Beyond max pools, least recently used first", func() {
+			pools.maxPools = 2
+			probe("10.0.0.1:3306")()
+			probe("10.0.0.2:3306")()
+			probe("10.0.0.1:3306")()
+			probe("10.0.0.3:3306")()
+			convey.So(targets(), convey.ShouldResemble, []string{"10.0.0.1:3306", "10.0.0.3:3306"})
+
+			// Pools of running probes are kept.
+			release := probe("10.0.0.1:3306")
+			probe("10.0.0.3:3306")
+			probe("10.0.0.4:3306")()
+			convey.So(targets(), convey.ShouldResemble, []string{"10.0.0.1:3306", "10.0.0.3:3306", "10.0.0.4:3306"})
+			release()
+		})
+
+		convey.Convey("When idle", func() {
+			pools.idleTimeout = time.Minute
+			probe("10.0.0.1:3306")()
+			release := probe("10.0.0.2:3306")
+			for _, entry := range pools.pools {
+				entry.lastUsed = time.Now().Add(-2 * time.Minute)
+			}
+			pools.expire()
+			convey.So(targets(), convey.ShouldResemble, []string{"10.0.0.2:3306"})
+			release()
+		})
+
+		convey.Convey("When their credentials change on reload", func() {
+			probe("10.0.0.1:3306")()
+			pools.setResolver(mycnfProbeDSN(cfg, config, tlsConfigs{}))
+			convey.So(targets(), convey.ShouldResemble, []string{"10.0.0.1:3306"})
+
+			changed, err := loadMycnf([]byte("[client]\nuser = root\npassword = changed\n"))
+			convey.So(err, convey.ShouldBeNil)
+			pools.setResolver(mycnfProbeDSN(changed, config, tlsConfigs{}))
+			convey.So(targets(), convey.ShouldBeEmpty)
+		})
+	})
+}
+
 // bin stores information about path of executable and attached port
 type bin struct {
 	path string
@@ -0,0 +1,326 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// WebConfig is the content of the file passed with --web.config.file.
+type WebConfig struct {
+	// TLSServerConfig enables TLS, it can only be added or removed with a restart.
+	TLSServerConfig *WebTLSConfig `yaml:"tls_server_config"`
+	// BasicAuthUsers maps user names to bcrypt hashes of their passwords.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// WebTLSConfig configures TLS, and client certificate authentication with a client CA.
+type WebTLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuthType is the name of a tls.ClientAuthType, it defaults to
+	// RequireAndVerifyClientCert with a client_ca_file and NoClientCert otherwise.
+	ClientAuthType string `yaml:"client_auth_type"`
+}
+
+var webClientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// loadedWebConfig is a validated WebConfig with its certificates loaded.
+type loadedWebConfig struct {
+	*WebConfig
+	tls *tls.Config
+	// dummyHash is checked for unknown users, so that they take as long as known ones.
+	dummyHash []byte
+
+	mu sync.Mutex
+	// authenticated holds the users and SHA-256 sums of the passwords that
+	// matched, bcrypt is too slow to run on every scrape.
+	authenticated map[string]bool
+}
+
+// readWebConfig reads and validates the web config file.
+func readWebConfig(filename string) (*WebConfig, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &WebConfig{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %s", filename, err)
+	}
+	for user, hash := range cfg.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid %s: basic_auth_users: %s: %s", filename, user, err)
+		}
+	}
+	return cfg, nil
+}
+
+// load loads the certificates of the web config read from filename.
+func (c *WebConfig) load(filename string) (*loadedWebConfig, error) {
+	loaded := &loadedWebConfig{WebConfig: c, authenticated: map[string]bool{}}
+	if c.TLSServerConfig != nil {
+		var err error
+		if loaded.tls, err = c.TLSServerConfig.load(); err != nil {
+			return nil, fmt.Errorf("invalid %s: tls_server_config: %s", filename, err)
+		}
+	}
+	if len(c.BasicAuthUsers) > 0 {
+		cost := bcrypt.MinCost
+		for _, hash := range c.BasicAuthUsers {
+			// Validated by readWebConfig.
+			if userCost, _ := bcrypt.Cost([]byte(hash)); userCost > cost {
+				cost = userCost
+			}
+		}
+		var err error
+		if loaded.dummyHash, err = bcrypt.GenerateFromPassword([]byte("dummy"), cost); err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+// files returns the certificate and key files the web config refers to.
+func (c *WebConfig) files() []string {
+	if c.TLSServerConfig == nil {
+		return nil
+	}
+	var files []string
+	for _, file := range []string{c.TLSServerConfig.CertFile, c.TLSServerConfig.KeyFile, c.TLSServerConfig.ClientCAFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// authenticate reports whether password is the one of user.
+func (c *loadedWebConfig) authenticate(user, password string) bool {
+	sum := sha256.Sum256([]byte(password))
+	key := user + "\x00" + string(sum[:])
+	c.mu.Lock()
+	ok := c.authenticated[key]
+	c.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	hash, known := c.BasicAuthUsers[user]
+	if !known {
+		hash = string(c.dummyHash)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !known {
+		return false
+	}
+	c.mu.Lock()
+	c.authenticated[key] = true
+	c.mu.Unlock()
+	return true
+}
+
+func (c *WebTLSConfig) load() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("cert_file and key_file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.ClientAuthType != "" {
+		clientAuth, ok := webClientAuthTypes[c.ClientAuthType]
+		if !ok {
+			return nil, fmt.Errorf("unknown client_auth_type %q", c.ClientAuthType)
+		}
+		if config.ClientCAs == nil && (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) {
+			return nil, fmt.Errorf("client_ca_file is required for client_auth_type %s", c.ClientAuthType)
+		}
+		config.ClientAuth = clientAuth
+	}
+	return config, nil
+}
+
+// webServer serves HTTP with the TLS and basic auth settings of the web config
+// file. The file is reloaded when it or the certificates it refers to change,
+// an invalid file is logged and the previous settings are kept.
+type webServer struct {
+	configFile string
+
+	mu      sync.Mutex
+	stamps  map[string]fileStamp
+	current *loadedWebConfig
+}
+
+// fileStamp tells versions of a file apart, the zero value stands for a missing file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(filename string) fileStamp {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// newWebServer loads the web config file, an empty name disables TLS and basic auth.
+func newWebServer(configFile string) (*webServer, error) {
+	s := &webServer{configFile: configFile}
+	if configFile == "" {
+		s.current = &loadedWebConfig{WebConfig: &WebConfig{}}
+		return s, nil
+	}
+	if _, err := s.config(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// config returns the current settings, reloading the file if it changed.
+func (s *webServer) config() (*loadedWebConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configFile == "" {
+		return s.current, nil
+	}
+
+	changed := s.current == nil
+	for file, stamp := range s.stamps {
+		if statFile(file) != stamp {
+			changed = true
+		}
+	}
+	if !changed {
+		return s.current, nil
+	}
+
+	// Retry only once one of the files changes again.
+	stamps := map[string]fileStamp{s.configFile: statFile(s.configFile)}
+	cfg, err := readWebConfig(s.configFile)
+	var loaded *loadedWebConfig
+	if err == nil {
+		for _, file := range cfg.files() {
+			stamps[file] = statFile(file)
+		}
+		loaded, err = cfg.load(s.configFile)
+	}
+	if err == nil && s.current != nil && (loaded.tls == nil) != (s.current.tls == nil) {
+		err = fmt.Errorf("enabling or disabling TLS in %s requires a restart", s.configFile)
+	}
+	if err != nil {
+		if s.current == nil {
+			return nil, err
+		}
+		log.Errorln("Error reloading web config, keeping the previous one:", err)
+	} else {
+		if s.current != nil {
+			log.Infoln("Reloaded web config")
+		}
+		s.current = loaded
+	}
+	s.stamps = stamps
+	return s.current, nil
+}
+
+// handler requires basic auth for h if the web config has users.
+func (s *webServer) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg, err := s.config()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(cfg.BasicAuthUsers) > 0 {
+			user, password, ok := r.BasicAuth()
+			if !ok || !cfg.authenticate(user, password) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="mysqld_exporter"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serve accepts connections on l, with TLS if the web config has a tls_server_config.
+// Certificates and client CAs are taken from the current settings on every handshake.
+// GetCertificate is set as well: ServeTLS of older Go versions, such as the
+// one releases are built with, only uses a config with Certificates or
+// GetCertificate and loads the empty file names otherwise.
+func (s *webServer) serve(server *http.Server, l net.Listener) error {
+	cfg, err := s.config()
+	if err != nil {
+		return err
+	}
+	if cfg.tls == nil {
+		return server.Serve(l)
+	}
+	server.TLSConfig = &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cfg, err := s.config()
+			if err != nil {
+				return nil, err
+			}
+			if cfg.tls == nil || len(cfg.tls.Certificates) == 0 {
+				return nil, fmt.Errorf("no server certificate configured")
+			}
+			return &cfg.tls.Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg, err := s.config()
+			if err != nil {
+				return nil, err
+			}
+			return cfg.tls, nil
+		},
+	}
+	return server.ServeTLS(l, "", "")
+}
@@ -0,0 +1,291 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testCert is a certificate and key written to PEM files.
+type testCert struct {
+	cert              *x509.Certificate
+	key               *ecdsa.PrivateKey
+	certFile, keyFile string
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA without parent.
+func newTestCert(t *testing.T, dir, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testCert{key: key, certFile: filepath.Join(dir, name+".crt"), keyFile: filepath.Join(dir, name+".key")}
+	if c.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// writeWebConfig writes the web config and moves its modification time
+// forward, so that a rewrite within the same clock tick is seen as a change.
+func writeWebConfig(t *testing.T, filename, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startWebServer serves a handler answering "ok" on a loopback port and returns
+// its address. stop closes the server and returns the error of serve.
+func startWebServer(t *testing.T, configFile string) (addr string, server *http.Server, stop func() error) {
+	web, err := newWebServer(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = &http.Server{Handler: web.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))}
+	served := make(chan error, 1)
+	go func() { served <- web.serve(server, l) }()
+	return l.Addr().String(), server, func() error {
+		server.Close()
+		return <-served
+	}
+}
+
+func webGet(client *http.Client, url, user, password string) (int, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestWebServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqld_exporter-web-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+	ca := newTestCert(t, dir, "ca", nil, x509.ExtKeyUsageAny)
+	server := newTestCert(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, dir, "client", ca, x509.ExtKeyUsageClientAuth)
+
+	convey.Convey("Without web config", t, func() {
+		addr, _, stop := startWebServer(t, "")
+		code, err := webGet(http.DefaultClient, "http://"+addr+"/metrics", "", "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+		convey.So(stop(), convey.ShouldEqual, http.ErrServerClosed)
+	})
+
+	convey.Convey("Basic auth is reloaded when the file changes", t, func() {
+		configFile := filepath.Join(dir, "web-auth.yml")
+		writeWebConfig(t, configFile, fmt.Sprintf("basic_auth_users: {alice: '%s'}", hash("secret")))
+		addr, _, stop := startWebServer(t, configFile)
+		url := "http://" + addr + "/metrics"
+
+		code, err := webGet(http.DefaultClient, url, "", "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(code, convey.ShouldEqual, http.StatusUnauthorized)
+		code, _ = webGet(http.DefaultClient, url, "alice", "wrong")
+		convey.So(code, convey.ShouldEqual, http.StatusUnauthorized)
+		code, _ = webGet(http.DefaultClient, url, "mallory", "secret")
+		convey.So(code, convey.ShouldEqual, http.StatusUnauthorized)
+		code, _ = webGet(http.DefaultClient, url, "alice", "secret")
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+
+		writeWebConfig(t, configFile, fmt.Sprintf("basic_auth_users: {bob: '%s'}", hash("other")))
+		code, _ = webGet(http.DefaultClient, url, "alice", "secret")
+		convey.So(code, convey.ShouldEqual, http.StatusUnauthorized)
+		code, _ = webGet(http.DefaultClient, url, "bob", "other")
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+
+		// An invalid file keeps the previous users.
+		writeWebConfig(t, configFile, "basic_auth_users: {bob: plain}")
+		code, _ = webGet(http.DefaultClient, url, "bob", "other")
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+		convey.So(stop(), convey.ShouldEqual, http.ErrServerClosed)
+	})
+
+	convey.Convey("TLS with client certificates", t, func() {
+		configFile := filepath.Join(dir, "web-tls.yml")
+		writeWebConfig(t, configFile, fmt.Sprintf(`
+tls_server_config:
+  cert_file: %s
+  key_file: %s
+  client_ca_file: %s
+basic_auth_users:
+  alice: '%s'
+`, server.certFile, server.keyFile, ca.certFile, hash("secret")))
+		addr, httpServer, stop := startWebServer(t, configFile)
+		url := "https://" + addr + "/metrics"
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		clientCert, err := tls.LoadX509KeyPair(client.certFile, client.keyFile)
+		convey.So(err, convey.ShouldBeNil)
+		withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: roots, Certificates: []tls.Certificate{clientCert},
+		}}}
+		withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+		_, err = webGet(withoutCert, url, "alice", "secret")
+		convey.So(err, convey.ShouldNotBeNil)
+		code, err := webGet(withCert, url, "", "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(code, convey.ShouldEqual, http.StatusUnauthorized)
+		code, err = webGet(withCert, url, "alice", "secret")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+
+		// A rotated certificate is picked up by the next handshake.
+		rotated := newTestCert(t, dir, "rotated", ca, x509.ExtKeyUsageServerAuth)
+		for from, to := range map[string]string{rotated.certFile: server.certFile, rotated.keyFile: server.keyFile} {
+			content, err := ioutil.ReadFile(from)
+			convey.So(err, convey.ShouldBeNil)
+			writeWebConfig(t, to, string(content))
+		}
+		withCert.CloseIdleConnections()
+		resp, err := withCert.Get(url)
+		convey.So(err, convey.ShouldBeNil)
+		resp.Body.Close()
+		convey.So(resp.TLS.PeerCertificates[0].SerialNumber, convey.ShouldResemble, rotated.cert.SerialNumber)
+
+		// Dropping the client CA is picked up by the next handshake.
+		writeWebConfig(t, configFile, fmt.Sprintf("tls_server_config: {cert_file: %s, key_file: %s}", server.certFile, server.keyFile))
+		withoutCert.CloseIdleConnections()
+		code, err = webGet(withoutCert, url, "", "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(code, convey.ShouldEqual, http.StatusOK)
+
+		// The certificate is also available without GetConfigForClient, which
+		// ServeTLS of older Go versions does not look at.
+		convey.So(stop(), convey.ShouldEqual, http.ErrServerClosed)
+		cert, err := httpServer.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+		convey.So(err, convey.ShouldBeNil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(leaf.SerialNumber, convey.ShouldResemble, rotated.cert.SerialNumber)
+	})
+
+	convey.Convey("Passwords are checked once", t, func() {
+		cfg, err := (&WebConfig{BasicAuthUsers: map[string]string{"alice": hash("secret")}}).load("web.yml")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(cfg.dummyHash, convey.ShouldNotBeEmpty)
+
+		convey.So(cfg.authenticate("alice", "wrong"), convey.ShouldBeFalse)
+		convey.So(cfg.authenticate("bob", "secret"), convey.ShouldBeFalse)
+		convey.So(cfg.authenticated, convey.ShouldBeEmpty)
+		convey.So(cfg.authenticate("alice", "secret"), convey.ShouldBeTrue)
+		convey.So(cfg.authenticated, convey.ShouldHaveLength, 1)
+		convey.So(cfg.authenticate("alice", "secret"), convey.ShouldBeTrue)
+		convey.So(cfg.authenticate("bob", "secret"), convey.ShouldBeFalse)
+	})
+
+	errors := []struct {
+		name, config, err string
+	}{
+		{"unknown field", "basic_auth_user: {}", "field basic_auth_user not found"},
+		{"plain text password", "basic_auth_users: {alice: secret}", "basic_auth_users: alice:"},
+		{"missing key", fmt.Sprintf("tls_server_config: {cert_file: %s}", server.certFile), "cert_file and key_file are required"},
+		{"unknown client auth", fmt.Sprintf("tls_server_config: {cert_file: %s, key_file: %s, client_auth_type: Always}", server.certFile, server.keyFile), `unknown client_auth_type "Always"`},
+		{"verify without ca", fmt.Sprintf("tls_server_config: {cert_file: %s, key_file: %s, client_auth_type: RequireAndVerifyClientCert}", server.certFile, server.keyFile), "client_ca_file is required"},
+	}
+	convey.Convey("Invalid web configs", t, func() {
+		configFile := filepath.Join(dir, "web-invalid.yml")
+		for _, e := range errors {
+			writeWebConfig(t, configFile, e.config)
+			_, err := newWebServer(configFile)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, e.err)
+		}
+	})
+}